@@ -0,0 +1,68 @@
+package common
+
+import (
+	"errors"
+	"strings"
+)
+
+//shellSyntax are the characters which mean something to a shell when they
+//are not quoted: pipes, lists, redirections, expansions and globs
+const shellSyntax = "|&;<>()$`*?["
+
+//SplitCommand splits a command into its arguments the way a shell would,
+//honouring quotes and backslashes, but without any expansion. It also tells
+//if the command uses shell syntax, which only a shell can run
+func SplitCommand(command string) ([]string, bool, error) {
+	var args []string
+	var word strings.Builder
+	inWord, shell := false, false
+	var quote rune
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\", runes[i+1]) {
+				i++
+				word.WriteRune(runes[i])
+			} else {
+				//expansions still happen between double quotes
+				shell = shell || c == '$' || c == '`'
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			//a comment, or the home directory
+			shell = shell || (!inWord && (c == '#' || c == '~')) || strings.ContainsRune(shellSyntax, c)
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, false, errors.New("unterminated quote")
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, shell, nil
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCommand(t *testing.T) {
+	for command, expected := range map[string][]string{
+		"/bin/sleep  100":                     {"/bin/sleep", "100"},
+		`/bin/sh -c "echo 'hi' && sleep 100"`: {"/bin/sh", "-c", "echo 'hi' && sleep 100"},
+		`grep 'a|b' "x y" z\ w ""`:            {"grep", "a|b", "x y", "z w", ""},
+		`echo "say \"hi\"" 'it''s' a"b"c`:     {"echo", `say "hi"`, "its", "abc"},
+		"":                                    nil,
+	} {
+		args, shell, err := SplitCommand(command)
+		assert.Nil(t, err, command)
+		assert.False(t, shell, command)
+		assert.Equal(t, expected, args, command)
+	}
+	for _, command := range []string{
+		"tail -f log | grep error",
+		"make && make install",
+		"a; b",
+		"app > out.log",
+		"echo $HOME",
+		`echo "$HOME"`,
+		"echo `date`",
+		"ls *.log",
+		"ls ~/logs",
+		"app # comment",
+	} {
+		_, shell, err := SplitCommand(command)
+		assert.Nil(t, err, command)
+		assert.True(t, shell, command)
+	}
+	_, _, err := SplitCommand(`echo "hi`)
+	assert.NotNil(t, err)
+}
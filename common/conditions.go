@@ -0,0 +1,115 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Time given to a condition command or port to answer
+const conditionTimeout = 5 * time.Second
+
+//Conditions must all be met before a process is started. Until then the
+//process is WAITING, and they are checked again every Interval seconds
+type Conditions struct {
+	Paths    []string
+	Mounts   []string
+	Ports    []string
+	Commands []string
+	Interval uint
+}
+
+func (p *Process) GetConditions() Conditions {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.Conditions
+}
+
+func validConditions(c Conditions) bool {
+	for _, port := range c.Ports {
+		if _, _, err := net.SplitHostPort(port); err != nil {
+			return false
+		}
+	}
+	for _, command := range c.Commands {
+		if len(strings.Fields(command)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+//CheckConditions returns a description of the first unmet start condition
+//of the process, or an empty string when the process can be started
+func (p *Process) CheckConditions() string {
+	c := p.GetConditions()
+	for _, path := range c.Paths {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Sprintf("path %s does not exist", path)
+		}
+	}
+	if len(c.Mounts) > 0 {
+		mounts := mountPoints()
+		for _, path := range c.Mounts {
+			if !mounts[filepath.Clean(path)] {
+				return fmt.Sprintf("%s is not mounted", path)
+			}
+		}
+	}
+	for _, address := range c.Ports {
+		conn, err := net.DialTimeout("tcp", address, conditionTimeout)
+		if err != nil {
+			return fmt.Sprintf("port %s is not reachable", address)
+		}
+		conn.Close()
+	}
+	for _, command := range c.Commands {
+		if err := p.runCondition(command); err != nil {
+			return fmt.Sprintf("command %s failed: %s", command, err)
+		}
+	}
+	return ""
+}
+
+//runCondition runs a condition command with the environment of the process
+func (p *Process) runCondition(command string) error {
+	spl := strings.Fields(command)
+	cmd := exec.Command(spl[0], spl[1:]...)
+	cmd.Dir = p.GetWorkingDir()
+	cmd.Env = append(p.environ(), EnvProcessName+"="+p.GetName())
+	if err := StartCmd(cmd); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(conditionTimeout, func() {
+		cmd.Process.Kill()
+	})
+	err := WaitCmd(cmd)
+	if !timer.Stop() {
+		err = fmt.Errorf("timed out after %s", conditionTimeout)
+	}
+	return err
+}
+
+//mountPoints returns the mount points of the server
+func mountPoints() map[string]bool {
+	mounts := make(map[string]bool)
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return mounts
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 4 {
+			//spaces in paths are escaped as \040
+			mounts[strings.Replace(fields[4], `\040`, " ", -1)] = true
+		}
+	}
+	return mounts
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConditions(t *testing.T) {
+	p := NewProc()
+	p.Name = "test"
+	assert.Equal(t, "", p.CheckConditions())
+
+	p.Conditions.Paths = []string{"/", "/no/such/path"}
+	assert.Equal(t, "path /no/such/path does not exist", p.CheckConditions())
+	p.Conditions.Paths = nil
+
+	p.Conditions.Mounts = []string{"/"}
+	assert.Equal(t, "", p.CheckConditions())
+	p.Conditions.Mounts = []string{"/no/such/path"}
+	assert.Equal(t, "/no/such/path is not mounted", p.CheckConditions())
+	p.Conditions.Mounts = nil
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if assert.Nil(t, err) {
+		p.Conditions.Ports = []string{l.Addr().String()}
+		assert.Equal(t, "", p.CheckConditions())
+		l.Close()
+		assert.NotEqual(t, "", p.CheckConditions())
+	}
+	p.Conditions.Ports = nil
+
+	p.Conditions.Commands = []string{"/bin/true"}
+	assert.Equal(t, "", p.CheckConditions())
+	p.Conditions.Commands = []string{"/bin/true", "/bin/false"}
+	assert.Contains(t, p.CheckConditions(), "command /bin/false failed")
+}
+
+func TestValidConditions(t *testing.T) {
+	assert.True(t, validConditions(Conditions{Ports: []string{"localhost:80"}}))
+	assert.False(t, validConditions(Conditions{Ports: []string{"localhost"}}))
+	assert.False(t, validConditions(Conditions{Commands: []string{"  "}}))
+}
@@ -9,49 +9,151 @@ import (
 )
 
 const (
-	Stopped                = "STOPPED"
-	Running                = "RUNNING"
-	Starting               = "STARTING"
-	Fatal                  = "FATAL"
-	Exited                 = "EXITED"
-	Stopping               = "STOPPING"
-	Backoff                = "BACKOFF"
-	Never                  = "Never"
-	Always                 = "Always"
-	Unexpected             = "Unexpected"
-	DflUmask        uint32 = 022
-	DflStopSignal          = syscall.SIGTERM
-	DflAutoRestart         = Unexpected
-	DflAutoStart           = false
-	DflStartRetries        = 3
-	DflStopTime     uint   = 10
-	DflStartTime    uint   = 10
-	DflNumProcs     uint   = 1
+	Stopped                     = "STOPPED"
+	Running                     = "RUNNING"
+	Starting                    = "STARTING"
+	Fatal                       = "FATAL"
+	Exited                      = "EXITED"
+	Stopping                    = "STOPPING"
+	Backoff                     = "BACKOFF"
+	Quarantined                 = "QUARANTINED"
+	Waiting                     = "WAITING"
+	Succeeded                   = "SUCCEEDED"
+	Failed                      = "FAILED"
+	Never                       = "Never"
+	Always                      = "Always"
+	Unexpected                  = "Unexpected"
+	TypeSimple                  = "simple"
+	TypeOneshot                 = "oneshot"
+	ActionRestart               = "Restart"
+	ActionStop                  = "Stop"
+	ActionAlert                 = "Alert"
+	ReasonExited                = "exited"
+	ReasonStopped               = "stopped"
+	ReasonWatchdog              = "watchdog"
+	HookPreStart                = "pre-start"
+	HookPostStart               = "post-start"
+	HookPreStop                 = "pre-stop"
+	HookPostStop                = "post-stop"
+	HookReload                  = "reload"
+	HookAbort                   = "abort"
+	HookIgnore                  = "ignore"
+	DflUmask             uint32 = 022
+	DflStopSignal               = syscall.SIGTERM
+	DflAutoRestart              = Unexpected
+	DflAutoStart                = false
+	DflType                     = TypeSimple
+	DflStartRetries             = 3
+	DflStopTime          uint   = 10
+	DflStartTime         uint   = 10
+	DflNumProcs          uint   = 1
+	DflMaxRSSAction             = ActionRestart
+	DflMaxRSSGrace       uint   = 10
+	DflHistorySize              = 16
+	DflHookTimeout       uint   = 30
+	DflDebounce          uint   = 500
+	DflConditionInterval uint   = 5
+	DflCrashDir                 = "./taskmaster_crashes"
+	DflCrashLines               = 50
+	DflRestartWindow     uint   = 60
+	EnvProcessName              = "TASKMASTER_PROCESS"
+	ReasonNotifyWatchdog        = "notify-watchdog"
+	ReasonTimeout               = "timeout"
+	ExitUnknown                 = -1
 )
 
 type Process struct {
-	ProcStatus
-	Name         string
-	NumProcs     uint
-	Command      string
-	Umask        uint32
-	Outfile      string
-	Errfile      string
-	Stdout       *os.File
-	Stderr       *os.File
-	WorkingDir   string
-	Cmd          *exec.Cmd
-	Env          []string
-	AutoStart    bool
-	AutoRestart  string
-	ExitCodes    []int
-	StartTime    uint
-	StartRetries uint
-	StopSignal   syscall.Signal
-	StopTime     uint
-	Killed       bool
-	Lock         *sync.RWMutex
-	Die          chan chan bool
+	ProcStatus     `json:"-"`
+	Name           string
+	Type           string
+	NumProcs       uint
+	PortPerProc    bool
+	Command        string
+	Shell          bool
+	Umask          uint32
+	Nice           int
+	IOClass        string
+	IOPriority     int
+	CPUAffinity    []int
+	OOMScoreAdjust int
+	Rlimits        map[string]uint64
+	User           string
+	Group          string
+	Namespaces     []string
+	PrivateTmp     bool
+	ReadOnlyPaths  []string
+	Chroot         string
+	Outfile        string
+	Errfile        string
+	Stdout         *os.File `json:"-"`
+	Stderr         *os.File `json:"-"`
+	WorkingDir     string
+	Cmd            *exec.Cmd `json:"-"`
+	Env            []string
+	InheritEnv     bool
+	AutoStart      bool
+	AutoRestart    string
+	ExitCodes      []int
+	StartTime      uint
+	StartRetries   uint
+	StopSignal     syscall.Signal
+	StopTime       uint
+	StopSequence   []StopStep
+	ReloadSignal   syscall.Signal
+	ReloadCommand  string
+	Reloadable     []string
+	Hooks          Hooks
+	Notify         bool
+	WatchdogSec    uint
+	Notifier       *Notifier `json:"-"`
+	Sockets        []string
+	LazyStart      bool
+	Watch          Watch
+	Conditions     Conditions
+	Requires       []string
+	RestartLimit   uint
+	RestartWindow  uint
+	Restarts       []time.Time `json:"-"`
+	CoreDumps      bool
+	CrashDir       string
+	CrashLines     int
+	MaxRuntime     uint
+	MaxRSS         uint64
+	MaxRSSAction   string
+	MaxRSSGrace    uint
+	Killed         bool           `json:"-"`
+	StopReason     string         `json:"-"`
+	History        []ExitRecord   `json:"-"`
+	Adopted        int            `json:"-"`
+	Lock           *sync.RWMutex  `json:"-"`
+	Die            chan chan bool `json:"-"`
+	StateSince     time.Time      `json:"-"`
+	Tries          uint           `json:"-"`
+	RestartCount   uint           `json:"-"`
+	NextRetry      time.Time      `json:"-"`
+	LastError      string         `json:"-"`
+	PreStopPid     int            `json:"-"`
+	ManualStop     bool           `json:"-"`
+}
+
+//Watch lists the files whose changes restart a process, or send it Signal
+//when it is set. Debounce is in milliseconds
+type Watch struct {
+	Paths    []string
+	Ignore   []string
+	Debounce uint
+	Signal   syscall.Signal
+}
+
+//ExitRecord describes how a run of a process ended
+type ExitRecord struct {
+	Pid        int
+	Start      time.Time
+	End        time.Time
+	ExitCode   int
+	Signal     syscall.Signal
+	CoreDumped bool
+	Reason     string
 }
 
 //ProcStatus s
@@ -60,6 +162,12 @@ type ProcStatus struct {
 	Pid     int
 	State   string
 	Runtime time.Time
+	CPU     float64
+	RSS     uint64
+	Threads int
+	FDs     int
+	Strays  []int
+	Message string
 }
 
 //Wrapper for a server method call
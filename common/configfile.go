@@ -0,0 +1,260 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//SourceMap gives the line and column in a config file of an offset in its
+//JSON form
+type SourceMap func(offset int64) (line, column int)
+
+//ReadConfig reads a config file as JSON. YAML files, chosen by their .yaml
+//or .yml extension, are converted to JSON with the same schema, and
+//supervisord files, chosen by their .ini or .conf extension, and Procfiles
+//are imported. It also returns the warnings about what could not be imported
+func ReadConfig(filename string, procfile ProcfileOptions) ([]byte, SourceMap, []string, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var converted []byte
+	var positions SourceMap
+	var warnings []string
+	if IsProcfile(filename) {
+		return readProcfile(filename, content, procfile)
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		converted, positions, err = YAMLToJSON(content)
+	case ".ini", ".conf":
+		here, _ := filepath.Abs(filepath.Dir(filename))
+		converted, positions, warnings, err = SupervisordToJSON(content, here)
+	default:
+		return content, jsonSourceMap(content), nil, nil
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	for i := range warnings {
+		warnings[i] = filename + ": " + warnings[i]
+	}
+	return converted, positions, warnings, nil
+}
+
+//ConfigError locates a JSON decoding error in the config file it comes from
+func ConfigError(filename string, err error, positions SourceMap) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	line, column := positions(offset)
+	return fmt.Errorf("%s:%d:%d: %s", filename, line, column, err)
+}
+
+//jsonSourceMap locates offsets in a JSON file
+func jsonSourceMap(content []byte) SourceMap {
+	return func(offset int64) (int, int) {
+		if offset > int64(len(content)) {
+			offset = int64(len(content))
+		}
+		before := content[:offset]
+		line := bytes.Count(before, []byte{'\n'}) + 1
+		return line, len(before) - bytes.LastIndexByte(before, '\n')
+	}
+}
+
+//jsonWriter writes JSON values, remembering the position in the source file
+//each of them comes from
+type jsonWriter struct {
+	buf       bytes.Buffer
+	offsets   []int64
+	positions [][2]int
+}
+
+//mark records that what is written next comes from line and column
+func (w *jsonWriter) mark(line, column int) {
+	w.offsets = append(w.offsets, int64(w.buf.Len()))
+	w.positions = append(w.positions, [2]int{line, column})
+}
+
+func (w *jsonWriter) write(key string, value interface{}, line, column int) {
+	w.mark(line, column)
+	encodedKey, _ := marshal(key)
+	encoded, _ := marshal(value)
+	w.buf.Write(encodedKey)
+	w.buf.WriteByte(':')
+	w.buf.Write(encoded)
+}
+
+//marshal encodes a value without escaping the characters special to HTML,
+//which are common in commands
+func marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+func (w *jsonWriter) position(offset int64) (int, int) {
+	i := sort.Search(len(w.offsets), func(i int) bool { return w.offsets[i] >= offset })
+	if i == 0 {
+		return 1, 1
+	}
+	return w.positions[i-1][0], w.positions[i-1][1]
+}
+
+//yamlConverter writes the JSON form of a YAML document
+type yamlConverter struct {
+	jsonWriter
+}
+
+//YAMLToJSON converts a YAML document to JSON. Errors are reported with the
+//line of the YAML document, and its column when it is known
+func YAMLToJSON(content []byte) ([]byte, SourceMap, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil, fmt.Errorf("empty document")
+	}
+	c := &yamlConverter{}
+	if err := c.convert(doc.Content[0]); err != nil {
+		return nil, nil, err
+	}
+	return c.buf.Bytes(), c.position, nil
+}
+
+func (c *yamlConverter) errorf(n *yaml.Node, format string, values ...interface{}) error {
+	return fmt.Errorf("line %d, column %d: %s", n.Line, n.Column, fmt.Sprintf(format, values...))
+}
+
+func (c *yamlConverter) convert(n *yaml.Node) error {
+	c.mark(n.Line, n.Column)
+	switch n.Kind {
+	case yaml.AliasNode:
+		return c.convert(n.Alias)
+	case yaml.MappingNode:
+		return c.convertMapping(n)
+	case yaml.SequenceNode:
+		c.buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				c.buf.WriteByte(',')
+			}
+			if err := c.convert(item); err != nil {
+				return err
+			}
+		}
+		c.buf.WriteByte(']')
+		return nil
+	case yaml.ScalarNode:
+		return c.convertScalar(n)
+	}
+	return c.errorf(n, "unsupported YAML node")
+}
+
+//convertMapping writes a mapping as an object. The pairs merged with << come
+//first so that the keys of the mapping override them
+func (c *yamlConverter) convertMapping(n *yaml.Node) error {
+	var pairs []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Kind == yaml.ScalarNode && key.ShortTag() == "!!merge" {
+			merged, err := mergedPairs(value)
+			if err != nil {
+				return err
+			}
+			pairs = append(append([]*yaml.Node{}, merged...), pairs...)
+		} else {
+			pairs = append(pairs, key, value)
+		}
+	}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i]
+		if key.ShortTag() == "!!merge" {
+			continue
+		}
+		if key.Kind != yaml.ScalarNode {
+			return c.errorf(key, "mapping keys must be scalars")
+		}
+		if seen[key.Value] {
+			return c.errorf(key, "duplicate key %s", key.Value)
+		}
+		seen[key.Value] = true
+	}
+	c.buf.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			c.buf.WriteByte(',')
+		}
+		key, _ := marshal(pairs[i].Value)
+		c.mark(pairs[i].Line, pairs[i].Column)
+		c.buf.Write(key)
+		c.buf.WriteByte(':')
+		if err := c.convert(pairs[i+1]); err != nil {
+			return err
+		}
+	}
+	c.buf.WriteByte('}')
+	return nil
+}
+
+//mergedPairs returns the pairs of the mappings merged with <<
+func mergedPairs(n *yaml.Node) ([]*yaml.Node, error) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		return n.Content, nil
+	case yaml.SequenceNode:
+		//the first mappings win, so they come last
+		var pairs []*yaml.Node
+		for i := len(n.Content) - 1; i >= 0; i-- {
+			merged, err := mergedPairs(n.Content[i])
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, merged...)
+		}
+		return pairs, nil
+	}
+	return nil, fmt.Errorf("line %d, column %d: only mappings can be merged", n.Line, n.Column)
+}
+
+func (c *yamlConverter) convertScalar(n *yaml.Node) error {
+	var value interface{}
+	switch n.ShortTag() {
+	case "!!int", "!!float", "!!bool", "!!null":
+		if err := n.Decode(&value); err != nil {
+			return c.errorf(n, "%s", err)
+		}
+	default:
+		//strings, and timestamps or binaries kept as written
+		value = n.Value
+	}
+	encoded, err := marshal(value)
+	if err != nil {
+		return c.errorf(n, "%s", err)
+	}
+	c.buf.Write(encoded)
+	return nil
+}
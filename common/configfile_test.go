@@ -0,0 +1,69 @@
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlConfig = `# comments are allowed
+Password: ""
+ProgList:
+  - &defaults
+    Name: web
+    Command: /bin/sleep 100
+    AutoStart: true
+    StartRetries: 5
+    Env: [A=1, B=2]
+  - <<: *defaults
+    Name: worker
+    StartRetries: 1
+`
+
+func TestYAMLToJSON(t *testing.T) {
+	converted, _, err := YAMLToJSON([]byte(yamlConfig))
+	assert.Nil(t, err)
+	var config struct {
+		Password string
+		ProgList []Process
+	}
+	assert.Nil(t, json.Unmarshal(converted, &config))
+	assert.Equal(t, 2, len(config.ProgList))
+	assert.Equal(t, "web", config.ProgList[0].Name)
+	assert.Equal(t, uint(5), config.ProgList[0].StartRetries)
+	assert.Equal(t, []string{"A=1", "B=2"}, config.ProgList[0].Env)
+	//merged keys are overridden by the keys of the mapping
+	assert.Equal(t, "worker", config.ProgList[1].Name)
+	assert.Equal(t, "/bin/sleep 100", config.ProgList[1].Command)
+	assert.Equal(t, uint(1), config.ProgList[1].StartRetries)
+
+	_, _, err = YAMLToJSON([]byte("ProgList:\n  - Name: a\n    Name: b\n"))
+	assert.EqualError(t, err, "line 3, column 5: duplicate key Name")
+	_, _, err = YAMLToJSON([]byte("ProgList: [\n"))
+	assert.NotNil(t, err)
+}
+
+func TestConfigError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskmaster")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.yml")
+	ioutil.WriteFile(filename, []byte("ProgList:\n  - Name: web\n    StartRetries: many\n"), 0644)
+	converted, positions, _, err := ReadConfig(filename, ProcfileOptions{})
+	assert.Nil(t, err)
+	var config struct{ ProgList []Process }
+	err = ConfigError(filename, json.Unmarshal(converted, &config), positions)
+	assert.Contains(t, err.Error(), filename+":3:19: ")
+
+	filename = filepath.Join(dir, "config.json")
+	ioutil.WriteFile(filename, []byte("{\n  \"ProgList\": [\n    {\"Name\": 42}\n  ]\n}\n"), 0644)
+	converted, positions, _, err = ReadConfig(filename, ProcfileOptions{})
+	assert.Nil(t, err)
+	err = ConfigError(filename, json.Unmarshal(converted, &config), positions)
+	assert.Contains(t, err.Error(), filename+":3:16: ")
+}
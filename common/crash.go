@@ -0,0 +1,250 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//Largest part of an output file read to find its last lines
+const crashTailBytes = 1 << 20
+
+//CrashReport describes a run of a process killed by a signal. It is saved
+//as report.json in the crash directory, next to the output tails, the
+//environment and the core file
+type CrashReport struct {
+	Name    string
+	Command string
+	Record  ExitRecord
+	Signal  string
+	Core    string
+	dir     string
+	env     []string
+	outfile string
+	errfile string
+	lines   int
+	core    string
+}
+
+func (p *Process) GetCoreDumps() bool {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.CoreDumps
+}
+func (p *Process) GetCrashDir() string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.CrashDir
+}
+
+//newCrashReport gathers what must be saved about a crash while the command
+//of the process is still the one which crashed
+func (p *Process) newCrashReport(record ExitRecord) *CrashReport {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	r := &CrashReport{
+		Name:    p.Name,
+		Command: p.Command,
+		Record:  record,
+		Signal:  record.Signal.String(),
+		outfile: p.Outfile,
+		errfile: p.Errfile,
+		lines:   p.CrashLines,
+	}
+	r.dir = filepath.Join(p.CrashDir, p.Name, record.End.Format("20060102-150405")+"-"+strconv.Itoa(record.Pid))
+	for _, v := range p.Cmd.Env {
+		if !strings.HasPrefix(v, EnvLaunchSpec+"=") {
+			r.env = append(r.env, v)
+		}
+	}
+	if record.CoreDumped {
+		dir := p.WorkingDir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		r.core = findCore(dir, record.Pid, record.Start)
+	}
+	return r
+}
+
+//Save writes the report and its artifacts in its crash directory
+func (r *CrashReport) Save() error {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+	if r.core != "" {
+		dest := filepath.Join(r.dir, "core")
+		if err := moveFile(r.core, dest); err != nil {
+			r.Core = fmt.Sprintf("left at %s: %s", r.core, err)
+		} else {
+			r.Core = "core"
+		}
+	} else if r.Record.CoreDumped {
+		r.Core = "not found, see /proc/sys/kernel/core_pattern"
+	}
+	if r.outfile != "" {
+		ioutil.WriteFile(filepath.Join(r.dir, "stdout.tail"), tailLines(r.outfile, r.lines), 0644)
+	}
+	if r.errfile != "" {
+		ioutil.WriteFile(filepath.Join(r.dir, "stderr.tail"), tailLines(r.errfile, r.lines), 0644)
+	}
+	env := strings.Join(r.env, "\n") + "\n"
+	if err := ioutil.WriteFile(filepath.Join(r.dir, "environ"), []byte(env), 0600); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(r.dir, "report.json"), content, 0644)
+}
+
+func (r *CrashReport) String() string {
+	core := "no core"
+	if r.Core == "core" {
+		core = "core saved"
+	}
+	return fmt.Sprintf("%s: pid %d killed by signal %d (%s) at %s after %s, %s",
+		filepath.Base(r.dir), r.Record.Pid, r.Record.Signal, r.Signal,
+		r.Record.End.Format("2006/01/02 15:04:05"),
+		r.Record.End.Sub(r.Record.Start).Truncate(time.Second), core)
+}
+
+//ListCrashes returns the crash reports of a process, oldest first
+func (p *Process) ListCrashes() ([]*CrashReport, error) {
+	dir := filepath.Join(p.GetCrashDir(), p.GetName())
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	reports := []*CrashReport{}
+	for _, entry := range entries {
+		content, err := ioutil.ReadFile(filepath.Join(dir, entry.Name(), "report.json"))
+		if err != nil {
+			continue
+		}
+		r := &CrashReport{dir: filepath.Join(dir, entry.Name())}
+		if json.Unmarshal(content, r) == nil {
+			reports = append(reports, r)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Record.End.Before(reports[j].Record.End)
+	})
+	return reports, nil
+}
+
+//findCore looks for the core file of pid according to the core pattern of
+//the kernel. Cores piped to a program cannot be found
+func findCore(dir string, pid int, since time.Time) string {
+	content, err := ioutil.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		return ""
+	}
+	pattern := strings.TrimSpace(string(content))
+	if pattern == "" || pattern[0] == '|' {
+		return ""
+	}
+	var glob bytes.Buffer
+	hasPid := false
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			glob.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'p', 'P':
+			glob.WriteString(strconv.Itoa(pid))
+			hasPid = true
+		case '%':
+			glob.WriteByte('%')
+		default:
+			glob.WriteByte('*')
+		}
+	}
+	if !hasPid {
+		if usesPid, _ := ioutil.ReadFile("/proc/sys/kernel/core_uses_pid"); strings.TrimSpace(string(usesPid)) == "1" {
+			glob.WriteString("." + strconv.Itoa(pid))
+		}
+	}
+	path := glob.String()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	matches, _ := filepath.Glob(path)
+	var core string
+	var newest time.Time
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(since) {
+			continue
+		}
+		if info.ModTime().After(newest) {
+			core, newest = match, info.ModTime()
+		}
+	}
+	return core
+}
+
+//moveFile renames a file, copying it when it is on another filesystem
+func moveFile(src, dest string) error {
+	err := os.Rename(src, dest)
+	if err == nil {
+		return nil
+	}
+	if linkErr, ok := err.(*os.LinkError); !ok || linkErr.Err != syscall.EXDEV {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+//tailLines returns the last n lines of a file
+func tailLines(path string, n int) []byte {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size() > crashTailBytes {
+		file.Seek(-crashTailBytes, io.SeekEnd)
+	}
+	content, err := ioutil.ReadAll(file)
+	if err != nil || len(content) == 0 {
+		return nil
+	}
+	content = bytes.TrimSuffix(content, []byte{'\n'})
+	lines := bytes.Split(content, []byte{'\n'})
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append(bytes.Join(lines, []byte{'\n'}), '\n')
+}
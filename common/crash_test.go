@@ -0,0 +1,40 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testcrash")
+	if err != nil {
+		t.Skip("unable to create test dir")
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out")
+	ioutil.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644)
+	assert.Equal(t, "two\nthree\n", string(tailLines(path, 2)))
+	assert.Equal(t, "one\ntwo\nthree\n", string(tailLines(path, 10)))
+	ioutil.WriteFile(path, nil, 0644)
+	assert.Nil(t, tailLines(path, 2))
+	assert.Nil(t, tailLines(filepath.Join(dir, "missing"), 2))
+}
+
+func TestMoveFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testcrash")
+	if err != nil {
+		t.Skip("unable to create test dir")
+	}
+	defer os.RemoveAll(dir)
+	src, dest := filepath.Join(dir, "core.1"), filepath.Join(dir, "core")
+	ioutil.WriteFile(src, []byte("core"), 0600)
+	assert.Nil(t, moveFile(src, dest))
+	_, err = os.Stat(src)
+	assert.True(t, os.IsNotExist(err))
+	content, _ := ioutil.ReadFile(dest)
+	assert.Equal(t, "core", string(content))
+}
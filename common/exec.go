@@ -0,0 +1,165 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+//ExecRequest asks the server to run Args with the settings of a process
+type ExecRequest struct {
+	Name string
+	Args []string
+}
+
+//ExecOutput is what a transient command wrote since the last read. ExitCode
+//is only meaningful once Done is set
+type ExecOutput struct {
+	Stdout   []byte
+	Stderr   []byte
+	Done     bool
+	ExitCode int
+}
+
+//ExecMaxOutput is how much output of each stream a transient command keeps
+//until it is read. What it writes beyond is dropped
+const ExecMaxOutput = 1 << 20
+
+//Exec is a transient command run with the user, working directory,
+//environment and isolation of a process. Its output is kept until read
+type Exec struct {
+	Name     string
+	Cmd      *exec.Cmd
+	lock     sync.Mutex
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	dropped  int
+	lastRead time.Time
+	done     bool
+	code     int
+	update   chan bool
+	ended    chan bool
+}
+
+type execWriter struct {
+	e   *Exec
+	buf *bytes.Buffer
+}
+
+func (w execWriter) Write(data []byte) (int, error) {
+	w.e.lock.Lock()
+	defer w.e.lock.Unlock()
+	kept := data
+	if room := ExecMaxOutput - w.buf.Len(); len(kept) > room {
+		kept = kept[:room]
+	}
+	w.buf.Write(kept)
+	w.e.dropped += len(data) - len(kept)
+	w.e.notify()
+	return len(data), nil
+}
+
+//NewExec prepares args to be run like the process would be, without
+//touching the process itself
+func (p *Process) NewExec(args []string) (*Exec, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("No command to run")
+	}
+	p.Lock.RLock()
+	t := *p
+	p.Lock.RUnlock()
+	t.Lock = &sync.RWMutex{}
+	t.Cmd = exec.Command(args[0], args[1:]...)
+	if t.WorkingDir != "" {
+		t.Cmd.Dir = t.WorkingDir
+	}
+	t.Cmd.Env = append(t.environ(), EnvProcessName+"="+t.Name)
+	if LauncherPath != "" {
+		if err := t.initLauncher(); err != nil {
+			return nil, err
+		}
+	} else if len(t.namespaces()) > 0 {
+		return nil, fmt.Errorf("Unable to isolate process %s without the launcher", t.Name)
+	}
+	e := &Exec{Name: t.Name, Cmd: t.Cmd, update: make(chan bool, 1), ended: make(chan bool)}
+	e.Cmd.Stdout = execWriter{e, &e.stdout}
+	e.Cmd.Stderr = execWriter{e, &e.stderr}
+	return e, nil
+}
+
+//Start runs the command in the background
+func (e *Exec) Start() error {
+	if err := StartCmd(e.Cmd); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	e.lastRead = time.Now()
+	e.lock.Unlock()
+	go func() {
+		WaitCmd(e.Cmd)
+		e.lock.Lock()
+		e.done = true
+		e.code = exitCode(e.Cmd.ProcessState)
+		e.notify()
+		e.lock.Unlock()
+		close(e.ended)
+	}()
+	return nil
+}
+
+//Ended is closed once the command has ended
+func (e *Exec) Ended() <-chan bool {
+	return e.ended
+}
+
+func (e *Exec) ExitCode() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.code
+}
+
+//LastRead tells when the output of the command was last asked for
+func (e *Exec) LastRead() time.Time {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.lastRead
+}
+
+//notify wakes up a pending Read, the lock must be held
+func (e *Exec) notify() {
+	select {
+	case e.update <- true:
+	default:
+	}
+}
+
+//Read returns the output written since the last read, waiting at most
+//timeout for something to happen
+func (e *Exec) Read(timeout time.Duration) ExecOutput {
+	e.lock.Lock()
+	e.lastRead = time.Now()
+	if e.stdout.Len() == 0 && e.stderr.Len() == 0 && !e.done {
+		e.lock.Unlock()
+		select {
+		case <-e.update:
+		case <-time.After(timeout):
+		}
+		e.lock.Lock()
+	}
+	defer e.lock.Unlock()
+	out := ExecOutput{
+		Stdout:   append([]byte{}, e.stdout.Bytes()...),
+		Stderr:   append([]byte{}, e.stderr.Bytes()...),
+		Done:     e.done,
+		ExitCode: e.code,
+	}
+	if e.dropped > 0 {
+		out.Stderr = append(out.Stderr, fmt.Sprintf("taskmaster: %d bytes of output dropped\n", e.dropped)...)
+		e.dropped = 0
+	}
+	e.stdout.Reset()
+	e.stderr.Reset()
+	return out
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec(t *testing.T) {
+	p := NewProc()
+	p.Name = "test"
+	p.WorkingDir = "/tmp"
+	p.Env = []string{"FOO=bar"}
+	e, err := p.NewExec([]string{"/bin/sh", "-c", "echo $FOO $PWD $" + EnvProcessName + "; echo oops >&2; exit 3"})
+	assert.Nil(t, err)
+	assert.Nil(t, e.Start())
+	var stdout, stderr []byte
+	out := ExecOutput{}
+	for !out.Done {
+		out = e.Read(time.Second)
+		stdout = append(stdout, out.Stdout...)
+		stderr = append(stderr, out.Stderr...)
+	}
+	assert.Equal(t, "bar /tmp test\n", string(stdout))
+	assert.Equal(t, "oops\n", string(stderr))
+	assert.Equal(t, 3, out.ExitCode)
+	//the process itself is left untouched
+	assert.Nil(t, p.Cmd)
+	assert.Equal(t, Stopped, p.GetProcStatus().State)
+
+	_, err = p.NewExec(nil)
+	assert.NotNil(t, err)
+}
+
+func TestExecMaxOutput(t *testing.T) {
+	p := NewProc()
+	p.Name = "test"
+	e, err := p.NewExec([]string{"/bin/sh", "-c", "head -c 1500000 /dev/zero"})
+	assert.Nil(t, err)
+	assert.Nil(t, e.Start())
+	<-e.Ended()
+	out := e.Read(time.Second)
+	assert.True(t, out.Done)
+	assert.Equal(t, ExecMaxOutput, len(out.Stdout))
+	assert.Contains(t, string(out.Stderr), "bytes of output dropped")
+	assert.True(t, time.Since(e.LastRead()) < time.Second)
+}
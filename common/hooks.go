@@ -0,0 +1,117 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"taskmaster/log"
+	"time"
+)
+
+//Hooks are commands run by the server around the transitions of a process
+type Hooks struct {
+	PreStart          string
+	PostStart         string
+	PreStop           string
+	PostStop          string
+	Timeout           uint
+	OnPreStartFailure string
+}
+
+func (p *Process) GetHooks() Hooks {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.Hooks
+}
+
+func validHooks(h Hooks) bool {
+	return (h.OnPreStartFailure == HookAbort || h.OnPreStartFailure == HookIgnore) && h.Timeout > 0
+}
+
+//RunHook runs the hook of the given kind, if any, and waits for it at most
+//the hook timeout. The hook gets the environment of the process, plus the
+//pid and the last exit code of the process. Its output goes to the
+//taskmaster log. The hook runs in its own process group, which is killed
+//on timeout along with the children the hook left behind
+func (p *Process) RunHook(kind string, pid, exitCode int) error {
+	hooks := p.GetHooks()
+	var command string
+	switch kind {
+	case HookPreStart:
+		command = hooks.PreStart
+	case HookPostStart:
+		command = hooks.PostStart
+	case HookPreStop:
+		command = hooks.PreStop
+	case HookPostStop:
+		command = hooks.PostStop
+	case HookReload:
+		command = p.GetReloadCommand()
+	}
+	spl := strings.Fields(command)
+	if len(spl) == 0 {
+		return nil
+	}
+	name := p.GetName()
+	cmd := exec.Command(spl[0], spl[1:]...)
+	cmd.Dir = p.GetWorkingDir()
+	cmd.Env = append(p.environ(),
+		EnvProcessName+"="+name,
+		"TASKMASTER_HOOK="+kind,
+		"TASKMASTER_PID="+strconv.Itoa(pid),
+		"TASKMASTER_EXIT_CODE="+strconv.Itoa(exitCode))
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	logw.Info("Running %s hook of process %s: %s", kind, name, command)
+	if err := StartCmd(cmd); err != nil {
+		logw.Warning("Unable to run %s hook of process %s: %s", kind, name, err)
+		return err
+	}
+	//the output is only complete once every child of the hook is gone
+	timer := time.AfterFunc(time.Duration(hooks.Timeout)*time.Second, func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	})
+	err := WaitCmd(cmd)
+	timedOut := !timer.Stop()
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		logw.Info("[%s %s] %s", name, kind, scanner.Text())
+	}
+	if timedOut {
+		err = errors.New(fmt.Sprintf("timed out after %ds", hooks.Timeout))
+	}
+	if err != nil {
+		logw.Warning("The %s hook of process %s failed: %s", kind, name, err)
+	}
+	return err
+}
+
+//RunPreStop runs the pre-stop hook of the current run of a process ahead of
+//its stop, which then does not run it again
+func (p *Process) RunPreStop() {
+	pid := p.GetPid()
+	if pid <= 0 {
+		return
+	}
+	p.RunHook(HookPreStop, pid, 0)
+	p.Lock.Lock()
+	p.PreStopPid = pid
+	p.Lock.Unlock()
+}
+
+//TakePreStop tells if the pre-stop hook already ran for the run with pid,
+//and forgets it
+func (p *Process) TakePreStop(pid int) bool {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	done := p.PreStopPid == pid
+	p.PreStopPid = 0
+	return done
+}
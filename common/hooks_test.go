@@ -0,0 +1,63 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"taskmaster/log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hookScript(t *testing.T, dir, body string) string {
+	path := filepath.Join(dir, "hook.sh")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunHook(t *testing.T) {
+	logw.InitSilent()
+	dir, err := ioutil.TempDir("", "taskmaster_hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewProc()
+	p.Name = "test"
+	p.Env = []string{"FOO=bar"}
+	assert.Nil(t, p.RunHook(HookPreStart, 0, 0))
+
+	out := filepath.Join(dir, "out")
+	p.Hooks.PostStop = hookScript(t, dir, `echo "$FOO $TASKMASTER_PROCESS $TASKMASTER_HOOK $TASKMASTER_PID $TASKMASTER_EXIT_CODE" > `+out)
+	assert.Nil(t, p.RunHook(HookPostStop, 42, 3))
+	content, _ := ioutil.ReadFile(out)
+	assert.Equal(t, "bar test post-stop 42 3\n", string(content))
+
+	p.Hooks.PreStart = hookScript(t, dir, "exit 2")
+	assert.NotNil(t, p.RunHook(HookPreStart, 0, 0))
+}
+
+func TestRunHookTimeout(t *testing.T) {
+	logw.InitSilent()
+	dir, err := ioutil.TempDir("", "taskmaster_hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewProc()
+	p.Hooks.Timeout = 1
+	//the child keeps the output open after the hook itself has exited
+	p.Hooks.PreStop = hookScript(t, dir, "sleep 30 &\nexit 0")
+	start := time.Now()
+	err = p.RunHook(HookPreStop, 0, 0)
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	assert.True(t, validHooks(p.Hooks))
+	p.Hooks.Timeout = 0
+	assert.False(t, validHooks(p.Hooks))
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+//ProcInfo is the detailed status of a single process
+type ProcInfo struct {
+	Status       ProcStatus
+	Config       string
+	StateSince   time.Time
+	LastExit     *ExitRecord
+	RestartCount uint
+	Tries        uint
+	MaxTries     uint
+	NextRetry    time.Time
+	LastError    string
+}
+
+func (p *Process) SetLastError(param string) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.LastError = param
+}
+
+func (p *Process) GetLastError() string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.LastError
+}
+
+//SetTries records the number of the current start attempt of the process.
+//Every attempt after the first one is an automatic restart
+func (p *Process) SetTries(tries uint) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.Tries = tries
+	if tries > 1 {
+		p.RestartCount++
+	}
+}
+
+func (p *Process) SetNextRetry(t time.Time) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.NextRetry = t
+}
+
+//GetInfo returns the detailed status of the process, with its effective
+//configuration as JSON
+func (p *Process) GetInfo() (ProcInfo, error) {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	config, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return ProcInfo{}, err
+	}
+	info := ProcInfo{
+		Status:       p.ProcStatus,
+		Config:       string(config),
+		StateSince:   p.StateSince,
+		RestartCount: p.RestartCount,
+		Tries:        p.Tries,
+		NextRetry:    p.NextRetry,
+		LastError:    p.LastError,
+	}
+	if p.AutoRestart != Always || p.Type == TypeOneshot {
+		info.MaxTries = p.StartRetries + 1
+	}
+	if len(p.History) > 0 {
+		last := p.History[len(p.History)-1]
+		info.LastExit = &last
+	}
+	return info, nil
+}
+
+func (i *ProcInfo) String() string {
+	s := fmt.Sprintf("Name:        %s\n", i.Status.Name)
+	s += fmt.Sprintf("State:       %s", i.Status.State)
+	if !i.StateSince.IsZero() {
+		s += fmt.Sprintf(" for %s", time.Since(i.StateSince).Truncate(time.Second))
+	}
+	if i.Status.Pid != 0 {
+		s += fmt.Sprintf(" (pid %d)", i.Status.Pid)
+	}
+	if i.Status.Message != "" {
+		s += " - " + i.Status.Message
+	}
+	s += "\n"
+	if i.LastExit != nil {
+		s += fmt.Sprintf("Last exit:   code %d (%s) at %s\n", i.LastExit.ExitCode, i.LastExit.Reason,
+			i.LastExit.End.Format("2006/01/02 15:04:05"))
+	} else {
+		s += "Last exit:   none\n"
+	}
+	s += fmt.Sprintf("Restarts:    %d\n", i.RestartCount)
+	if i.MaxTries > 0 {
+		s += fmt.Sprintf("Tries:       %d/%d\n", i.Tries, i.MaxTries)
+	} else {
+		s += fmt.Sprintf("Tries:       %d\n", i.Tries)
+	}
+	if !i.NextRetry.IsZero() {
+		s += fmt.Sprintf("Next retry:  %s\n", i.NextRetry.Format("2006/01/02 15:04:05"))
+	}
+	if i.LastError != "" {
+		s += fmt.Sprintf("Last error:  %s\n", i.LastError)
+	}
+	return s + "Config:\n" + i.Config + "\n"
+}
@@ -0,0 +1,29 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetInfo(t *testing.T) {
+	p := NewProc()
+	p.Name = "test"
+	p.Command = "/bin/true"
+	p.SetTries(1)
+	p.SetTries(2)
+	p.AddExitRecord(ExitRecord{Pid: 42, ExitCode: 1, Reason: ReasonExited})
+	info, err := p.GetInfo()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), info.Tries)
+	assert.Equal(t, uint(1), info.RestartCount)
+	assert.Equal(t, uint(DflStartRetries+1), info.MaxTries)
+	assert.Equal(t, 42, info.LastExit.Pid)
+
+	var config map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(info.Config), &config))
+	assert.Equal(t, "/bin/true", config["Command"])
+	_, exists := config["History"]
+	assert.False(t, exists)
+}
@@ -0,0 +1,142 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB
+
+//Watcher reports, through Events, the files matching a set of glob patterns
+//which were created, modified, moved or deleted
+type Watcher struct {
+	Events    chan string
+	file      *os.File
+	fd        int
+	lock      *sync.Mutex
+	dirs      map[int]string
+	recursive map[int]bool
+	patterns  []string
+	ignore    []string
+}
+
+//NewWatcher watches the given patterns. A pattern naming a directory
+//matches every file below it, other patterns are matched with filepath.Match
+//against the full path of the changed files. Files whose name or path match
+//one of the ignore patterns are not reported
+func NewWatcher(patterns, ignore []string) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		Events:    make(chan string),
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		fd:        fd,
+		lock:      &sync.Mutex{},
+		dirs:      make(map[int]string),
+		recursive: make(map[int]bool),
+		ignore:    ignore,
+	}
+	for _, pattern := range patterns {
+		pattern = filepath.Clean(pattern)
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			w.addTree(pattern)
+			w.patterns = append(w.patterns, filepath.Join(pattern, "**"))
+			continue
+		}
+		dirs, err := filepath.Glob(filepath.Dir(pattern))
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		for _, dir := range dirs {
+			w.addDir(dir, false)
+		}
+		w.patterns = append(w.patterns, pattern)
+	}
+	go w.read()
+	return w, nil
+}
+
+func (w *Watcher) addDir(dir string, recursive bool) {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		return
+	}
+	w.lock.Lock()
+	w.dirs[wd] = dir
+	w.recursive[wd] = recursive
+	w.lock.Unlock()
+}
+
+func (w *Watcher) addTree(root string) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			w.addDir(path, true)
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) matches(path string) bool {
+	for _, pattern := range w.ignore {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return false
+		}
+	}
+	for _, pattern := range w.patterns {
+		if strings.HasSuffix(pattern, string(filepath.Separator)+"**") {
+			if strings.HasPrefix(path, strings.TrimSuffix(pattern, "**")) {
+				return true
+			}
+		} else if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Watcher) read() {
+	defer close(w.Events)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+			w.lock.Lock()
+			dir, known := w.dirs[int(event.Wd)]
+			recursive := w.recursive[int(event.Wd)]
+			w.lock.Unlock()
+			if !known || name == "" {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if recursive && event.Mask&syscall.IN_ISDIR != 0 && event.Mask&syscall.IN_CREATE != 0 {
+				w.addTree(path)
+			}
+			if w.matches(path) {
+				w.Events <- path
+			}
+		}
+	}
+}
+
+//Close stops the watcher, Events is closed once it is done
+func (w *Watcher) Close() {
+	w.file.Close()
+}
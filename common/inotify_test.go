@@ -0,0 +1,51 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nextEvent(w *Watcher) string {
+	select {
+	case path := <-w.Events:
+		return path
+	case <-time.After(2 * time.Second):
+		return ""
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testwatcher")
+	if err != nil {
+		t.Skip("Failed to create test dir")
+	}
+	defer os.RemoveAll(dir)
+	w, err := NewWatcher([]string{filepath.Join(dir, "*.go")}, []string{"*_test.go"})
+	assert.Nil(t, err)
+	defer w.Close()
+	ioutil.WriteFile(filepath.Join(dir, "main_test.go"), []byte("ignored"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0644)
+	assert.Equal(t, filepath.Join(dir, "main.go"), nextEvent(w))
+}
+
+func TestWatcherRecursive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testwatcher")
+	if err != nil {
+		t.Skip("Failed to create test dir")
+	}
+	defer os.RemoveAll(dir)
+	w, err := NewWatcher([]string{dir}, nil)
+	assert.Nil(t, err)
+	defer w.Close()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	assert.Equal(t, filepath.Join(dir, "sub"), nextEvent(w))
+	time.Sleep(100 * time.Millisecond)
+	ioutil.WriteFile(filepath.Join(dir, "sub", "file"), nil, 0644)
+	assert.Equal(t, filepath.Join(dir, "sub", "file"), nextEvent(w))
+}
@@ -0,0 +1,235 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+//EnvLaunchSpec holds the attributes the launcher applies to a program
+const EnvLaunchSpec = "TASKMASTER_LAUNCH"
+
+//LauncherPath is the binary started in launcher mode in place of each
+//program. When it is empty programs are executed directly and only their
+//working directory is applied
+var LauncherPath string
+
+//LaunchSpec is what the launcher applies to itself before executing Path
+type LaunchSpec struct {
+	Path    string
+	Umask   uint32
+	Sched   Sched
+	Rlimits map[string]uint64
+	Dir     string
+	User    string
+	Group   string
+	//isolation, see namespaces.go
+	Mount         bool
+	Proc          bool
+	Loopback      bool
+	PrivateTmp    bool
+	ReadOnlyPaths []string
+	Chroot        string
+}
+
+var rlimitNames = map[string]int{
+	"as":      syscall.RLIMIT_AS,
+	"core":    syscall.RLIMIT_CORE,
+	"cpu":     syscall.RLIMIT_CPU,
+	"data":    syscall.RLIMIT_DATA,
+	"fsize":   syscall.RLIMIT_FSIZE,
+	"nofile":  syscall.RLIMIT_NOFILE,
+	"stack":   syscall.RLIMIT_STACK,
+	"nproc":   unix.RLIMIT_NPROC,
+	"memlock": unix.RLIMIT_MEMLOCK,
+}
+
+func validRlimits(rlimits map[string]uint64) bool {
+	for name := range rlimits {
+		if _, exists := rlimitNames[name]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+//initLauncher makes the command go through the launcher, which is given the
+//attributes of the process in its environment
+func (p *Process) initLauncher() error {
+	p.Lock.RLock()
+	spec := LaunchSpec{
+		Path:    p.Cmd.Args[0],
+		Umask:   p.Umask,
+		Rlimits: p.Rlimits,
+		Dir:     p.WorkingDir,
+		User:    p.User,
+		Group:   p.Group,
+	}
+	if _, exists := p.Rlimits["core"]; p.CoreDumps && !exists {
+		spec.Rlimits = map[string]uint64{"core": ^uint64(0)}
+		for name, value := range p.Rlimits {
+			spec.Rlimits[name] = value
+		}
+	}
+	p.Lock.RUnlock()
+	spec.Sched = p.GetSched()
+	if err := p.initNamespaces(&spec); err != nil {
+		return err
+	}
+	if spec.Chroot == "" && !strings.Contains(spec.Path, "/") {
+		//a bare command name is looked up in the PATH of the server, so that
+		//a missing command fails the start. The launcher looks up the others
+		//itself, inside the chroot or relative to the working directory
+		path, err := exec.LookPath(spec.Path)
+		if err != nil {
+			return err
+		}
+		spec.Path = path
+	}
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	p.Cmd.Path = LauncherPath
+	//the command may only exist inside the chroot
+	p.Cmd.Err = nil
+	p.Cmd.Dir = ""
+	p.Cmd.Env = append(p.Cmd.Env, EnvLaunchSpec+"="+string(encoded))
+	return nil
+}
+
+//Launch is the entry point of the launcher mode. It applies the encoded spec
+//to the current process then executes the program, keeping its pid and file
+//descriptors. It only returns on failure, exiting with status 127
+func Launch(encoded string) {
+	//some attributes are per thread until the exec
+	runtime.LockOSThread()
+	err := launch(encoded)
+	fmt.Fprintf(os.Stderr, "taskmaster launcher: %s\n", err)
+	os.Exit(127)
+}
+
+func launch(encoded string) error {
+	var spec LaunchSpec
+	if err := json.Unmarshal([]byte(encoded), &spec); err != nil {
+		return err
+	}
+	syscall.Umask(int(spec.Umask))
+	if err := spec.Sched.apply(); err != nil {
+		return err
+	}
+	for name, value := range spec.Rlimits {
+		limit := syscall.Rlimit{Cur: value, Max: value}
+		if err := syscall.Setrlimit(rlimitNames[name], &limit); err != nil {
+			return fmt.Errorf("unable to set rlimit %s: %s", name, err)
+		}
+	}
+	//users are looked up on the host, before any chroot
+	creds, err := lookupCredentials(spec.User, spec.Group)
+	if err != nil {
+		return err
+	}
+	if spec.Mount {
+		if err := setupMounts(&spec); err != nil {
+			return err
+		}
+	}
+	if spec.Loopback {
+		if err := loopbackUp(); err != nil {
+			return err
+		}
+	}
+	if err := creds.apply(); err != nil {
+		return err
+	}
+	if spec.Dir != "" {
+		if err := os.Chdir(spec.Dir); err != nil {
+			return err
+		}
+	}
+	if spec.Chroot != "" || !filepath.IsAbs(spec.Path) {
+		if spec.Path, err = exec.LookPath(spec.Path); err != nil {
+			return err
+		}
+	}
+	env := []string{}
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, EnvLaunchSpec+"=") {
+			env = append(env, v)
+		}
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		env = append(env, "LISTEN_PID="+strconv.Itoa(os.Getpid()))
+	}
+	return syscall.Exec(spec.Path, os.Args, env)
+}
+
+type credentials struct {
+	name   string
+	uid    int
+	gid    int
+	groups []int
+}
+
+//lookupCredentials finds the ids of the given user and group. The group
+//defaults to the primary group of the user, with its supplementary groups.
+//It returns nil when the process keeps the credentials of the server
+func lookupCredentials(name, group string) (*credentials, error) {
+	if name == "" && group == "" {
+		return nil, nil
+	}
+	if u, err := user.Current(); err == nil && u.Username == name && group == "" {
+		return nil, nil
+	}
+	c := &credentials{name: name, uid: -1, gid: -1}
+	if name != "" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		c.uid, _ = strconv.Atoi(u.Uid)
+		c.gid, _ = strconv.Atoi(u.Gid)
+		ids, _ := u.GroupIds()
+		for _, id := range ids {
+			n, _ := strconv.Atoi(id)
+			c.groups = append(c.groups, n)
+		}
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		c.gid, _ = strconv.Atoi(g.Gid)
+		c.groups = []int{c.gid}
+	}
+	return c, nil
+}
+
+//apply switches the current process to the credentials
+func (c *credentials) apply() error {
+	if c == nil {
+		return nil
+	}
+	if err := syscall.Setgroups(c.groups); err != nil {
+		return fmt.Errorf("unable to set groups of user %s: %s", c.name, err)
+	}
+	if err := syscall.Setgid(c.gid); err != nil {
+		return fmt.Errorf("unable to set group %d: %s", c.gid, err)
+	}
+	if c.uid != -1 {
+		if err := syscall.Setuid(c.uid); err != nil {
+			return fmt.Errorf("unable to set user %s: %s", c.name, err)
+		}
+	}
+	return nil
+}
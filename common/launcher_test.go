@@ -0,0 +1,22 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidRlimits(t *testing.T) {
+	assert.True(t, validRlimits(nil))
+	assert.True(t, validRlimits(map[string]uint64{"nofile": 1024, "core": 0}))
+	assert.False(t, validRlimits(map[string]uint64{"files": 1024}))
+}
+
+func TestLookupCredentials(t *testing.T) {
+	c, err := lookupCredentials("", "")
+	assert.Nil(t, err)
+	assert.Nil(t, c)
+	assert.Nil(t, c.apply())
+	_, err = lookupCredentials("no-such-user-here", "")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,161 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var namespaceFlags = map[string]uintptr{
+	"pid":   syscall.CLONE_NEWPID,
+	"mount": syscall.CLONE_NEWNS,
+	"net":   syscall.CLONE_NEWNET,
+	"uts":   syscall.CLONE_NEWUTS,
+	"ipc":   syscall.CLONE_NEWIPC,
+	"user":  syscall.CLONE_NEWUSER,
+}
+
+func validNamespaces(namespaces []string) bool {
+	for _, name := range namespaces {
+		if _, exists := namespaceFlags[name]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+//namespaces returns the namespaces of the process. The mount namespace is
+//implied by the options which change the filesystem of the process
+func (p *Process) namespaces() map[string]bool {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	namespaces := map[string]bool{}
+	for _, name := range p.Namespaces {
+		namespaces[name] = true
+	}
+	if p.PrivateTmp || len(p.ReadOnlyPaths) > 0 || p.Chroot != "" {
+		namespaces["mount"] = true
+	}
+	return namespaces
+}
+
+//checkNamespaces tells why the server cannot create the namespaces, if it
+//cannot. Without root, every namespace but the user one needs a user
+//namespace, and user namespaces must be enabled on the host
+func checkNamespaces(namespaces map[string]bool) error {
+	if namespaces["user"] {
+		content, err := ioutil.ReadFile("/proc/sys/user/max_user_namespaces")
+		if err == nil && strings.TrimSpace(string(content)) == "0" {
+			return errors.New("user namespaces are disabled on this host (user.max_user_namespaces is 0)")
+		}
+		content, err = ioutil.ReadFile("/proc/sys/kernel/unprivileged_userns_clone")
+		if err == nil && strings.TrimSpace(string(content)) == "0" && os.Geteuid() != 0 {
+			return errors.New("unprivileged user namespaces are disabled on this host (kernel.unprivileged_userns_clone is 0)")
+		}
+		return nil
+	}
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	for name := range namespaces {
+		return fmt.Errorf("the %s namespace needs the server to run as root, or the user namespace", name)
+	}
+	return nil
+}
+
+//initNamespaces makes the process start in new namespaces. Filesystem
+//changes are done by the launcher, inside the new mount namespace
+func (p *Process) initNamespaces(spec *LaunchSpec) error {
+	namespaces := p.namespaces()
+	if len(namespaces) == 0 {
+		return nil
+	}
+	if err := checkNamespaces(namespaces); err != nil {
+		return fmt.Errorf("Unable to isolate process %s: %s", p.GetName(), err)
+	}
+	attr := &syscall.SysProcAttr{}
+	for name := range namespaces {
+		attr.Cloneflags |= namespaceFlags[name]
+	}
+	if namespaces["user"] {
+		//the user of the server is root inside the namespace
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	}
+	p.Cmd.SysProcAttr = attr
+	p.Lock.RLock()
+	spec.Mount = namespaces["mount"]
+	spec.Proc = namespaces["mount"] && namespaces["pid"]
+	spec.Loopback = namespaces["net"]
+	spec.PrivateTmp = p.PrivateTmp
+	spec.ReadOnlyPaths = p.ReadOnlyPaths
+	spec.Chroot = p.Chroot
+	p.Lock.RUnlock()
+	return nil
+}
+
+//setupMounts builds the filesystem of the process in its mount namespace,
+//then changes its root directory
+func setupMounts(spec *LaunchSpec) error {
+	//keep our mounts from propagating to the host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("unable to make mounts private: %s", err)
+	}
+	root := spec.Chroot
+	if root == "" {
+		root = "/"
+	}
+	if spec.PrivateTmp {
+		if err := syscall.Mount("tmpfs", filepath.Join(root, "tmp"), "tmpfs", 0, "mode=1777"); err != nil {
+			return fmt.Errorf("unable to mount private /tmp: %s", err)
+		}
+	}
+	for _, path := range spec.ReadOnlyPaths {
+		//the paths are seen from inside the chroot
+		path = filepath.Join(root, path)
+		if err := syscall.Mount(path, path, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("unable to bind mount %s: %s", path, err)
+		}
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+		if err := syscall.Mount("", path, "", flags, ""); err != nil {
+			return fmt.Errorf("unable to make %s read-only: %s", path, err)
+		}
+	}
+	if spec.Proc {
+		if err := syscall.Mount("proc", filepath.Join(root, "proc"), "proc", 0, ""); err != nil {
+			return fmt.Errorf("unable to mount /proc: %s", err)
+		}
+	}
+	if spec.Chroot != "" {
+		if err := syscall.Chroot(spec.Chroot); err != nil {
+			return fmt.Errorf("unable to chroot to %s: %s", spec.Chroot, err)
+		}
+		if err := os.Chdir("/"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//loopbackUp brings up the loopback interface of a new network namespace
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	//struct ifreq: the interface name then the flags
+	var ifreq [40]byte
+	copy(ifreq[:], "lo")
+	*(*uint16)(unsafe.Pointer(&ifreq[syscall.IFNAMSIZ])) = syscall.IFF_UP | syscall.IFF_RUNNING
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifreq)))
+	if errno != 0 {
+		return fmt.Errorf("unable to bring up the loopback interface: %s", errno)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package common
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//Notifier is the NOTIFY_SOCKET of a process, through which it reports its
+//readiness, a free-text status and watchdog pings, as with systemd. Only the
+//process and its descendants are listened to
+type Notifier struct {
+	Path     string
+	Ready    chan bool
+	Done     chan bool
+	conn     *net.UnixConn
+	lock     *sync.Mutex
+	lastPing time.Time
+	onStatus func(string)
+	attached chan int
+}
+
+//NewNotifier creates the datagram socket of a process, which only the user
+//of the process, given by creds, may write to
+func NewNotifier(name string, creds *credentials, onStatus func(string)) (*Notifier, error) {
+	path := filepath.Join(os.TempDir(), "taskmaster-"+strconv.Itoa(os.Getpid())+"-"+name+".sock")
+	os.Remove(path)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	if err = restrictNotifySocket(conn, path, creds); err != nil {
+		conn.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	n := &Notifier{
+		Path:     path,
+		Ready:    make(chan bool, 1),
+		Done:     make(chan bool),
+		conn:     conn,
+		lock:     &sync.Mutex{},
+		lastPing: time.Now(),
+		onStatus: onStatus,
+		attached: make(chan int, 1),
+	}
+	go n.read()
+	return n, nil
+}
+
+//restrictNotifySocket makes the socket writable by the user of the process
+//only, and has the kernel tell the pid of the sender of every message
+func restrictNotifySocket(conn *net.UnixConn, path string, creds *credentials) error {
+	mode := os.FileMode(0600)
+	if creds != nil {
+		if creds.uid < 0 {
+			//only the group is changed
+			mode = 0620
+		}
+		if err := os.Chown(path, creds.uid, creds.gid); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
+	})
+	if err == nil {
+		err = sockErr
+	}
+	return err
+}
+
+//Attach tells the pid of the process once it is started. The messages sent
+//before are kept by the socket until then
+func (n *Notifier) Attach(pid int) {
+	n.attached <- pid
+}
+
+func (n *Notifier) read() {
+	var pid int
+	select {
+	case pid = <-n.attached:
+	case <-n.Done:
+		return
+	}
+	buf := make([]byte, 4096)
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofUcred))
+	for {
+		size, oobn, _, _, err := n.conn.ReadMsgUnix(buf, oob)
+		if err != nil {
+			return
+		}
+		sender := senderPid(oob[:oobn])
+		if sender != pid && !isDescendant(sender, pid) {
+			continue
+		}
+		for _, line := range strings.Split(string(buf[:size]), "\n") {
+			n.handle(line)
+		}
+	}
+}
+
+//senderPid returns the pid in the credentials of a message, 0 if none
+func senderPid(oob []byte) int {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, m := range messages {
+		if cred, err := syscall.ParseUnixCredentials(&m); err == nil {
+			return int(cred.Pid)
+		}
+	}
+	return 0
+}
+
+//isDescendant tells if pid is a descendant of root
+func isDescendant(pid, root int) bool {
+	for pid > 1 {
+		st, err := readProcStat(pid)
+		if err != nil {
+			return false
+		}
+		if st.Ppid == root {
+			return true
+		}
+		pid = st.Ppid
+	}
+	return false
+}
+
+func (n *Notifier) handle(line string) {
+	switch {
+	case line == "READY=1":
+		select {
+		case n.Ready <- true:
+		default:
+		}
+	case line == "WATCHDOG=1":
+		n.lock.Lock()
+		n.lastPing = time.Now()
+		n.lock.Unlock()
+	case strings.HasPrefix(line, "STATUS="):
+		n.onStatus(strings.TrimPrefix(line, "STATUS="))
+	}
+}
+
+//LastPing returns the time of the last watchdog ping, or the creation of the
+//socket if there was none
+func (n *Notifier) LastPing() time.Time {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.lastPing
+}
+
+func (n *Notifier) Close() {
+	close(n.Done)
+	n.conn.Close()
+	os.Remove(n.Path)
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func notify(t *testing.T, path, message string) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(message))
+}
+
+func TestNotifier(t *testing.T) {
+	status := make(chan string, 1)
+	n, err := NewNotifier("test", nil, func(s string) { status <- s })
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer n.Close()
+	info, err := os.Stat(n.Path)
+	if assert.Nil(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+	n.Attach(os.Getpid())
+	notify(t, n.Path, "STATUS=working\nREADY=1")
+	select {
+	case <-n.Ready:
+	case <-time.After(time.Second):
+		t.Error("READY=1 was ignored")
+	}
+	assert.Equal(t, "working", <-status)
+}
+
+func TestNotifierIgnoresStrangers(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	n, err := NewNotifier("test", nil, func(string) {})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer n.Close()
+	n.Attach(cmd.Process.Pid)
+	//the test is neither the process nor one of its descendants
+	notify(t, n.Path, "READY=1")
+	select {
+	case <-n.Ready:
+		t.Error("READY=1 from another process was accepted")
+	case <-time.After(200 * time.Millisecond):
+	}
+	assert.True(t, isDescendant(cmd.Process.Pid, os.Getpid()))
+	assert.False(t, isDescendant(os.Getpid(), cmd.Process.Pid))
+}
+
+func TestNotifyIsolation(t *testing.T) {
+	p := NewProc()
+	p.Name = "a"
+	p.Command = "ls"
+	p.Notify = true
+	p.StartTime = 1
+	assert.Nil(t, p.IsValid())
+	p.PrivateTmp = true
+	assert.NotNil(t, p.IsValid())
+	p.PrivateTmp = false
+	p.Chroot = "/srv"
+	assert.NotNil(t, p.IsValid())
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//TaskRequest asks for the end of a run of a oneshot task: the first run
+//ending after Since
+type TaskRequest struct {
+	Name  string
+	Since time.Time
+}
+
+//TaskResult is the outcome of a run of a oneshot task. Until Done, it only
+//tells the client what to wait for next
+type TaskResult struct {
+	State    string
+	ExitCode int
+	Done     bool
+	Since    time.Time
+}
+
+func (p *Process) GetType() string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.Type
+}
+
+func (p *Process) IsOneshot() bool {
+	return p.GetType() == TypeOneshot
+}
+
+func (p *Process) GetRequires() []string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.Requires
+}
+
+//IsDone tells if a oneshot task has finished, successfully or not
+func IsDone(state string) bool {
+	return state == Succeeded || state == Failed
+}
+
+//RequirementMet tells if a program required by another one is ready: a
+//oneshot task must have succeeded, any other program must be running
+func (p *Process) RequirementMet() bool {
+	state := p.GetProcStatus().State
+	if p.IsOneshot() {
+		return state == Succeeded
+	}
+	return state == Running
+}
+
+//RequirementFailed tells if a program required by another one has given up,
+//so that it will not be ready unless it is started again
+func (p *Process) RequirementFailed() bool {
+	switch p.GetProcStatus().State {
+	case Failed, Fatal, Exited, Quarantined:
+		return true
+	}
+	return false
+}
+
+//ExpandRequires makes the programs requiring a program with several
+//instances require each of them. Requires name the programs of the config,
+//before their instances are numbered
+func ExpandRequires(progs []Process) {
+	numProcs := make(map[string]uint, len(progs))
+	for _, p := range progs {
+		numProcs[p.Name] = p.NumProcs
+	}
+	for i := range progs {
+		var requires []string
+		for _, name := range progs[i].Requires {
+			if numProcs[name] <= 1 {
+				requires = append(requires, name)
+				continue
+			}
+			for n := uint(0); n < numProcs[name]; n++ {
+				requires = append(requires, name+strconv.Itoa(int(n)))
+			}
+		}
+		progs[i].Requires = requires
+	}
+}
+
+//CheckRequires makes sure every program required by another one exists, and
+//that no program requires itself, even through other programs
+func CheckRequires(procs map[string]*Process) error {
+	//0: not visited, 1: being visited, 2: done
+	visits := make(map[string]int, len(procs))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch visits[name] {
+		case 1:
+			return fmt.Errorf("Program %s requires itself", name)
+		case 2:
+			return nil
+		}
+		visits[name] = 1
+		for _, required := range procs[name].GetRequires() {
+			if _, exists := procs[required]; !exists {
+				return fmt.Errorf("Program %s requires %s, which does not exist", name, required)
+			}
+			if err := visit(required); err != nil {
+				return err
+			}
+		}
+		visits[name] = 2
+		return nil
+	}
+	for name := range procs {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
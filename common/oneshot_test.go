@@ -0,0 +1,71 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRequiring(name string, requires ...string) *Process {
+	p := NewProc()
+	p.Name = name
+	p.Requires = requires
+	return &p
+}
+
+func TestCheckRequires(t *testing.T) {
+	procs := map[string]*Process{
+		"web":     newRequiring("web", "migrate", "cache"),
+		"migrate": newRequiring("migrate", "cache"),
+		"cache":   newRequiring("cache"),
+	}
+	assert.Nil(t, CheckRequires(procs))
+
+	procs["cache"].Requires = []string{"web"}
+	assert.NotNil(t, CheckRequires(procs))
+
+	procs["cache"].Requires = []string{"cache"}
+	assert.NotNil(t, CheckRequires(procs))
+
+	procs["cache"].Requires = []string{"nope"}
+	assert.NotNil(t, CheckRequires(procs))
+}
+
+func TestRequirementMet(t *testing.T) {
+	task := newRequiring("migrate")
+	task.Type = TypeOneshot
+	task.State = Running
+	assert.False(t, task.RequirementMet())
+	task.State = Failed
+	assert.False(t, task.RequirementMet())
+	task.State = Succeeded
+	assert.True(t, task.RequirementMet())
+
+	daemon := newRequiring("cache")
+	assert.False(t, daemon.RequirementMet())
+	daemon.State = Running
+	assert.True(t, daemon.RequirementMet())
+}
+
+func TestExpandRequires(t *testing.T) {
+	web, worker, migrate := NewProc(), NewProc(), NewProc()
+	web.Name, web.NumProcs = "web", 2
+	worker.Name, worker.Requires = "worker", []string{"web", "migrate"}
+	migrate.Name, migrate.Requires = "migrate", []string{"web1"}
+	progs := []Process{web, worker, migrate}
+	ExpandRequires(progs)
+	assert.Equal(t, []string{"web0", "web1", "migrate"}, progs[1].Requires)
+	assert.Equal(t, []string{"web1"}, progs[2].Requires)
+}
+
+func TestRequirementFailed(t *testing.T) {
+	p := newRequiring("migrate")
+	for _, state := range []string{Stopped, Starting, Running, Waiting, Backoff, Succeeded} {
+		p.State = state
+		assert.False(t, p.RequirementFailed())
+	}
+	for _, state := range []string{Failed, Fatal, Exited, Quarantined} {
+		p.State = state
+		assert.True(t, p.RequirementFailed())
+	}
+}
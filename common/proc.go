@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -14,6 +15,7 @@ import (
 func NewProc() Process {
 	p := Process{}
 	p.ProcStatus = ProcStatus{State: Stopped}
+	p.Type = DflType
 	p.AutoRestart = DflAutoRestart
 	p.AutoStart = DflAutoStart
 	p.StartTime = DflStartTime
@@ -23,6 +25,14 @@ func NewProc() Process {
 	p.NumProcs = DflNumProcs
 	p.StopSignal = syscall.SIGINT
 	p.ExitCodes = []int{0, 2}
+	p.MaxRSSAction = DflMaxRSSAction
+	p.MaxRSSGrace = DflMaxRSSGrace
+	p.Hooks = Hooks{Timeout: DflHookTimeout, OnPreStartFailure: HookAbort}
+	p.Watch = Watch{Debounce: DflDebounce}
+	p.RestartWindow = DflRestartWindow
+	p.Conditions = Conditions{Interval: DflConditionInterval}
+	p.CrashDir = DflCrashDir
+	p.CrashLines = DflCrashLines
 	p.Lock = &sync.RWMutex{}
 	p.Die = make(chan chan bool)
 	return p
@@ -52,6 +62,63 @@ func (p *Process) SetRuntime(start time.Time) {
 	p.Runtime = start
 }
 
+func (p *Process) SetUsage(cpu float64, u Usage) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.CPU = cpu
+	p.RSS = u.RSS
+	p.Threads = u.Threads
+	p.FDs = u.FDs
+}
+
+func (p *Process) SetStrays(pids []int) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.Strays = pids
+}
+
+func (p *Process) SetMessage(message string) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.Message = message
+}
+
+func (p *Process) GetNotify() bool {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.Notify
+}
+
+func (p *Process) GetWatchdogSec() uint {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.WatchdogSec
+}
+
+func (p *Process) GetNotifier() *Notifier {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.Notifier
+}
+
+func (p *Process) GetSockets() []string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.Sockets
+}
+
+func (p *Process) GetLazyStart() bool {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.LazyStart
+}
+
+func (p *Process) GetWatch() Watch {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.Watch
+}
+
 func (p *Process) GetName() string {
 	p.Lock.RLock()
 	defer p.Lock.RUnlock()
@@ -167,6 +234,30 @@ func (p *Process) GetEnv() []string {
 	defer p.Lock.RUnlock()
 	return p.Env
 }
+
+//environ returns the environment of the process: the environment of the
+//server when Env is not set, Env alone, or Env added to the environment of
+//the server with InheritEnv
+func (p *Process) environ() []string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	if p.Env == nil {
+		return os.Environ()
+	} else if p.InheritEnv {
+		return append(os.Environ(), p.Env...)
+	}
+	return p.Env[:len(p.Env):len(p.Env)]
+}
+
+//commandArgs splits the command of the process, or gives it to the shell
+func (p *Process) commandArgs() []string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	if p.Shell {
+		return []string{"/bin/sh", "-c", p.Command}
+	}
+	return strings.Fields(p.Command)
+}
 func (p *Process) SetEnv(param []string) {
 	p.Lock.Lock()
 	defer p.Lock.Unlock()
@@ -253,11 +344,85 @@ func (p *Process) SetKilled(param bool) {
 	p.Killed = param
 }
 
-func (p *Process) SetStatus(state string) {
+func (p *Process) GetMaxRSS() uint64 {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.MaxRSS
+}
+func (p *Process) GetMaxRSSAction() string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.MaxRSSAction
+}
+func (p *Process) GetMaxRuntime() uint {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.MaxRuntime
+}
+func (p *Process) GetMaxRSSGrace() uint {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.MaxRSSGrace
+}
+func (p *Process) GetStopReason() string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.StopReason
+}
+func (p *Process) SetStopReason(param string) {
 	p.Lock.Lock()
 	defer p.Lock.Unlock()
+	p.StopReason = param
+}
+
+//GetHistory returns a copy of the exit history, oldest run first
+func (p *Process) GetHistory() []ExitRecord {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	history := make([]ExitRecord, len(p.History))
+	copy(history, p.History)
+	return history
+}
+
+//GetManualStop tells if the process was stopped by the operator, and has not
+//been started since
+func (p *Process) GetManualStop() bool {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.ManualStop
+}
+func (p *Process) SetManualStop(param bool) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.ManualStop = param
+}
+
+func (p *Process) SetHistory(history []ExitRecord) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.History = history
+}
+
+func (p *Process) AddExitRecord(record ExitRecord) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.History = append(p.History, record)
+	if len(p.History) > DflHistorySize {
+		p.History = p.History[len(p.History)-DflHistorySize:]
+	}
+}
+
+func (p *Process) SetStatus(state string) {
+	p.Lock.Lock()
+	if p.State != state {
+		p.StateSince = time.Now()
+	}
 	p.State = state
 	logw.Info("Process %s entered status %s", p.Name, state)
+	p.Lock.Unlock()
+	if StateHook != nil {
+		StateHook(p)
+	}
 }
 
 func (p *Process) IsValid() error {
@@ -273,6 +438,33 @@ func (p *Process) IsValid() error {
 		err = fmt.Errorf("A process has whitespaces in its name, the process will be ignored, please reload your config file\n")
 	case p.AutoRestart != "Always" && p.AutoRestart != "Never" && p.AutoRestart != "Unexpected":
 		err = fmt.Errorf("A process has an invalid AutoRestart value, the process will be ignored, please reload your config file\n")
+	case p.Type != TypeSimple && p.Type != TypeOneshot:
+		err = fmt.Errorf("A process has an invalid Type value, the process will be ignored, please reload your config file\n")
+	case !validStopSequence(p.StopSequence):
+		err = fmt.Errorf("A process has an invalid StopSequence signal, the process will be ignored, please reload your config file\n")
+	case !validSockets(p.Sockets):
+		err = fmt.Errorf("A process has an invalid socket address, the process will be ignored, please reload your config file\n")
+	case p.Notify && p.StartTime == 0:
+		err = fmt.Errorf("A process using Notify needs a StartTime to report its readiness, the process will be ignored, please reload your config file\n")
+	case p.PortPerProc && !validPortPerProc(p.Env):
+		err = fmt.Errorf("A process using PortPerProc needs a PORT number in its Env, the process will be ignored, please reload your config file\n")
+	case p.Notify && (p.PrivateTmp || p.Chroot != ""):
+		//the notify socket is in the temporary directory of the server
+		err = fmt.Errorf("A process using Notify cannot use PrivateTmp or Chroot, the process will be ignored, please reload your config file\n")
+	case !validHooks(p.Hooks):
+		err = fmt.Errorf("A process has an invalid Hooks.OnPreStartFailure or a zero Hooks.Timeout, the process will be ignored, please reload your config file\n")
+	case !validNamespaces(p.Namespaces):
+		err = fmt.Errorf("A process has an invalid Namespaces name, the process will be ignored, please reload your config file\n")
+	case !validSched(Sched{p.Nice, p.IOClass, p.IOPriority, p.CPUAffinity, p.OOMScoreAdjust}):
+		err = fmt.Errorf("A process has an invalid scheduling setting, the process will be ignored, please reload your config file\n")
+	case !validConditions(p.Conditions) || p.Conditions.Interval == 0:
+		err = fmt.Errorf("A process has invalid Conditions, the process will be ignored, please reload your config file\n")
+	case !validReloadable(p.Reloadable):
+		err = fmt.Errorf("A process has an invalid Reloadable option, the process will be ignored, please reload your config file\n")
+	case !validRlimits(p.Rlimits):
+		err = fmt.Errorf("A process has an invalid Rlimits name, the process will be ignored, please reload your config file\n")
+	case p.MaxRSSAction != ActionRestart && p.MaxRSSAction != ActionStop && p.MaxRSSAction != ActionAlert:
+		err = fmt.Errorf("A process has an invalid MaxRSSAction value, the process will be ignored, please reload your config file\n")
 	}
 	return err
 }
@@ -298,31 +490,84 @@ func (p *Process) InitStdout() error {
 }
 
 func (p *Process) Init() error {
-	spl := strings.Fields(p.GetCommand())
+	spl := p.commandArgs()
 	p.Cmd = exec.Command(spl[0], spl[1:]...)
 	wd := p.GetWorkingDir()
 	if wd != "" {
 		p.Cmd.Dir = wd
 	}
-	env := p.GetEnv()
-	if env != nil {
-		p.Cmd.Env = env
+	p.Cmd.Env = append(p.environ(), EnvProcessName+"="+p.GetName())
+	if err := p.initSockets(); err != nil {
+		return err
 	}
-	if p.Stderr == nil && p.GetErrfile() != "" {
-		p.CloseLogs()
-		if err := p.InitStderr(); err != nil {
+	if p.GetNotify() {
+		if err := p.initNotifier(); err != nil {
 			return err
 		}
 	}
-	if p.Stdout == nil && p.GetOutfile() != "" {
-		p.CloseLogs()
-		if err := p.InitStdout(); err != nil {
+	if LauncherPath != "" {
+		if err := p.initLauncher(); err != nil {
 			return err
 		}
+	} else if len(p.namespaces()) > 0 {
+		return fmt.Errorf("Unable to isolate process %s without the launcher", p.GetName())
+	}
+	if (p.Stderr == nil && p.GetErrfile() != "") || (p.Stdout == nil && p.GetOutfile() != "") {
+		p.CloseLogs()
+		if p.GetErrfile() != "" {
+			if err := p.InitStderr(); err != nil {
+				return err
+			}
+		}
+		if p.GetOutfile() != "" {
+			if err := p.InitStdout(); err != nil {
+				return err
+			}
+		}
+	}
+	//log files stay open across restarts
+	if p.Stderr != nil {
+		p.Cmd.Stderr = p.Stderr
+	}
+	if p.Stdout != nil {
+		p.Cmd.Stdout = p.Stdout
 	}
 	return nil
 }
 
+func (p *Process) initNotifier() error {
+	p.Lock.RLock()
+	name, group := p.User, p.Group
+	p.Lock.RUnlock()
+	creds, err := lookupCredentials(name, group)
+	if err != nil {
+		return err
+	}
+	n, err := NewNotifier(p.GetName(), creds, p.SetMessage)
+	if err != nil {
+		return err
+	}
+	p.Lock.Lock()
+	p.Notifier = n
+	p.Message = ""
+	p.Lock.Unlock()
+	p.Cmd.Env = append(p.Cmd.Env, "NOTIFY_SOCKET="+n.Path)
+	if sec := p.GetWatchdogSec(); sec > 0 {
+		p.Cmd.Env = append(p.Cmd.Env, "WATCHDOG_USEC="+strconv.FormatUint(uint64(sec)*1000000, 10))
+	}
+	return nil
+}
+
+func (p *Process) closeNotifier() {
+	p.Lock.Lock()
+	n := p.Notifier
+	p.Notifier = nil
+	p.Lock.Unlock()
+	if n != nil {
+		n.Close()
+	}
+}
+
 func (p *Process) CloseLogs() {
 	if p.Stderr != nil {
 		p.Stderr.Close()
@@ -335,10 +580,15 @@ func (p *Process) CloseLogs() {
 }
 
 func (p *Process) HasCorrectlyExit() bool {
-	if p.Killed {
-		return true
+	if p.GetKilled() {
+		//a process running for too long did not exit correctly
+		return p.GetStopReason() != ReasonTimeout
 	}
 	exitCode := p.GetExitCode()
+	if exitCode == ExitUnknown {
+		//the status of an adopted process is lost, it is not held against it
+		return true
+	}
 	codes := p.GetExitCodes()
 	for _, e := range codes {
 		if e == exitCode {
@@ -349,47 +599,108 @@ func (p *Process) HasCorrectlyExit() bool {
 }
 
 func (p *Process) GetExitCode() int {
-	exitCode := p.Cmd.ProcessState.Sys().(syscall.WaitStatus)
-	if exitCode > 128 {
-		exitCode = (exitCode >> 8) & 255
-	} else if exitCode > 0 {
-		exitCode += 128
+	if p.Cmd == nil || p.Cmd.ProcessState == nil {
+		//adopted processes are not our children, their status is unknown
+		return ExitUnknown
 	}
-	return int(exitCode)
+	return exitCode(p.Cmd.ProcessState)
+}
+
+func exitCode(state *os.ProcessState) int {
+	status := state.Sys().(syscall.WaitStatus)
+	if status.Signaled() {
+		//like shells, report a death by signal as 128 + the signal
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
 }
 
 func (p *ProcStatus) String() string {
-	if p.State == Running || p.State == Starting {
-		return fmt.Sprintf("%s: %s [%d] %.5s\n", p.Name, p.State, p.Pid, time.Since(p.Runtime).String())
+	var extra string
+	if len(p.Strays) > 0 {
+		extra = fmt.Sprintf(" strays %v", p.Strays)
+	}
+	if p.Message != "" {
+		extra += " - " + p.Message
+	}
+	if (p.State == Running || p.State == Starting) && p.Pid != 0 {
+		return fmt.Sprintf("%s: %s [%d] %.5s cpu %.1f%% mem %dkB threads %d fds %d%s\n",
+			p.Name, p.State, p.Pid, time.Since(p.Runtime).String(), p.CPU, p.RSS, p.Threads, p.FDs, extra)
 	} else {
-		return fmt.Sprintf("%s: %s\n", p.Name, p.State)
+		return fmt.Sprintf("%s: %s%s\n", p.Name, p.State, extra)
 	}
 }
 
 func (p *Process) Start(started, processEnd chan bool) {
-	oldMask := syscall.Umask(int(p.GetUmask()))
+	if p.GetAdopted() != 0 {
+		p.watchAdopted(started, processEnd)
+		return
+	}
 	err := p.Init()
 	if err != nil {
 		logw.Error(err.Error())
+		p.SetLastError(err.Error())
+		p.closeNotifier()
 		started <- false
 		return
 	}
-	if p.State == Starting || p.State == Running {
+	//a process starting without a pid is only past its pre-start hook
+	if (p.State == Starting || p.State == Running) && p.Pid != 0 {
 		logw.Error("Process %s already started", p.Name)
+		p.closeNotifier()
 		started <- false
 		return
 	}
-	err = p.Cmd.Start()
+	p.SetStopReason("")
+	err = StartCmd(p.Cmd)
 	if err != nil {
 		logw.Error(err.Error())
+		p.SetLastError(err.Error())
+		p.closeNotifier()
 		started <- false
 		return
 	}
-	syscall.Umask(oldMask)
-	p.SetRuntime(time.Now())
+	start := time.Now()
+	p.SetRuntime(start)
 	p.SetPid(p.Cmd.Process.Pid)
+	if n := p.GetNotifier(); n != nil {
+		n.Attach(p.Cmd.Process.Pid)
+	}
 	started <- true
-	err = p.Cmd.Wait()
-	processEnd <- true
+	err = WaitCmd(p.Cmd)
+	p.closeNotifier()
+	record := ExitRecord{
+		Pid:      p.Cmd.Process.Pid,
+		Start:    start,
+		End:      time.Now(),
+		ExitCode: p.GetExitCode(),
+		Reason:   p.exitReason(),
+	}
+	if status, ok := p.Cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		record.Signal = status.Signal()
+		record.CoreDumped = status.CoreDump()
+	}
+	p.AddExitRecord(record)
+	if record.Signal != 0 && !p.GetKilled() {
+		report := p.newCrashReport(record)
+		go func() {
+			if err := report.Save(); err != nil {
+				logw.Error("Unable to save crash report of %s: %s", p.Name, err)
+				return
+			}
+			logw.Alert("Process %s crashed with signal %d (%s): %s", p.Name, record.Signal, record.Signal, report)
+		}()
+	}
 	p.SetPid(0)
+	processEnd <- true
+}
+
+func (p *Process) exitReason() string {
+	if !p.GetKilled() {
+		return ReasonExited
+	}
+	if reason := p.GetStopReason(); reason != "" {
+		return reason
+	}
+	return ReasonStopped
 }
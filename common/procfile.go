@@ -0,0 +1,248 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//ProcfileOptions are the settings of a Procfile given outside of it
+type ProcfileOptions struct {
+	//Formation is the number of processes of each process type, as in
+	//web=2,worker=0. all=n applies to the types not listed
+	Formation string
+	//Env is the environment file, relative to the directory of the
+	//Procfile. It is ignored when it does not exist
+	Env string
+}
+
+//DflProcfileEnv is the environment file of a Procfile by default
+const DflProcfileEnv = ".env"
+
+//Like foreman, the process types get the ports 5000, 5100... unless the
+//environment sets the first one
+const procfileBasePort = 5000
+
+var procfileLine = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+
+//IsProcfile tells if a config file is a Procfile, such as Procfile.dev
+func IsProcfile(filename string) bool {
+	base := filepath.Base(filename)
+	return base == "Procfile" || strings.HasPrefix(base, "Procfile.")
+}
+
+//EnvPath returns the path of the environment file of a Procfile, or an empty
+//string when it has none
+func (o ProcfileOptions) EnvPath(filename string) string {
+	if o.Env == "" || filepath.IsAbs(o.Env) {
+		return o.Env
+	}
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, o.Env)
+}
+
+//readProcfile converts a Procfile to a config, with the environment file and
+//the formation of the Procfile
+func readProcfile(filename string, content []byte, options ProcfileOptions) ([]byte, SourceMap, []string, error) {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	formation, err := parseFormation(options.Formation)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid formation %s: %s", options.Formation, err)
+	}
+	var env []string
+	if path := options.EnvPath(filename); path != "" {
+		dotenv, err := ioutil.ReadFile(path)
+		if err == nil {
+			if env, err = parseDotenv(dotenv); err != nil {
+				return nil, nil, nil, fmt.Errorf("%s: %s", path, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, nil, nil, err
+		}
+	}
+	converted, positions, warnings, err := ProcfileToJSON(content, dir, env, formation)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	for i := range warnings {
+		warnings[i] = filename + ": " + warnings[i]
+	}
+	return converted, positions, warnings, nil
+}
+
+//parseFormation parses web=2,worker=0 into the number of processes of each
+//process type
+func parseFormation(spec string) (map[string]int, error) {
+	formation := map[string]int{}
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected type=number, got %s", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid number of processes for %s", parts[0])
+		}
+		formation[strings.TrimSpace(parts[0])] = n
+	}
+	return formation, nil
+}
+
+//ProcfileToJSON converts each name: command line of a Procfile to a program
+//run by the shell in dir, with env added to the environment of the server.
+//The formation gives the number of processes of each type, 1 by default.
+//The processes of a type are its NumProcs, each with its PORT
+func ProcfileToJSON(content []byte, dir string, env []string, formation map[string]int) ([]byte, SourceMap, []string, error) {
+	port := procfileBasePort
+	var typeEnv []string
+	for _, v := range env {
+		if !strings.HasPrefix(v, "PORT=") {
+			typeEnv = append(typeEnv, v)
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(v, "PORT="))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid PORT in the environment: %s", v)
+		}
+		port = n
+	}
+	w := &jsonWriter{}
+	var warnings []string
+	seen := map[string]bool{}
+	w.buf.WriteString(`{"ProgList":[`)
+	programs := 0
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		match := procfileLine.FindStringSubmatch(line)
+		if match == nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: expected name: command, ignored", i+1))
+			continue
+		}
+		name, command := match[1], match[2]
+		if seen[name] {
+			return nil, nil, nil, fmt.Errorf("line %d: duplicate process type %s", i+1, name)
+		}
+		seen[name] = true
+		//every process type gets its port, even when it does not run
+		typePort := port + 100*(len(seen)-1)
+		n, exists := formation[name]
+		if !exists {
+			n, exists = formation["all"]
+		}
+		if !exists {
+			n = 1
+		}
+		if n == 0 {
+			continue
+		}
+		column := strings.Index(line, command) + 1
+		if programs > 0 {
+			w.buf.WriteByte(',')
+		}
+		programs++
+		w.buf.WriteByte('{')
+		w.write("Name", name, i+1, 1)
+		w.buf.WriteByte(',')
+		w.write("Command", command, i+1, column)
+		w.buf.WriteByte(',')
+		w.write("Shell", true, i+1, column)
+		w.buf.WriteByte(',')
+		w.write("NumProcs", n, i+1, 1)
+		w.buf.WriteByte(',')
+		w.write("PortPerProc", true, i+1, 1)
+		w.buf.WriteByte(',')
+		w.write("WorkingDir", dir, i+1, 1)
+		w.buf.WriteByte(',')
+		w.write("Env", append(typeEnv[:len(typeEnv):len(typeEnv)], "PORT="+strconv.Itoa(typePort)), i+1, 1)
+		w.buf.WriteByte(',')
+		w.write("InheritEnv", true, i+1, 1)
+		w.buf.WriteByte(',')
+		w.write("AutoStart", true, i+1, 1)
+		w.buf.WriteByte(',')
+		w.write("StopSignal", syscall.SIGTERM, i+1, 1)
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteString(`]}`)
+	return w.buf.Bytes(), w.position, warnings, nil
+}
+
+//InstanceEnv returns the environment of the instance i of a program, where
+//PORT is increased by i when each instance has its own port
+func InstanceEnv(env []string, portPerProc bool, i uint) []string {
+	if !portPerProc {
+		return env
+	}
+	instance := make([]string, len(env))
+	for k, v := range env {
+		instance[k] = v
+		if !strings.HasPrefix(v, "PORT=") {
+			continue
+		}
+		if port, err := strconv.Atoi(strings.TrimPrefix(v, "PORT=")); err == nil {
+			instance[k] = "PORT=" + strconv.Itoa(port+int(i))
+		}
+	}
+	return instance
+}
+
+//validPortPerProc tells if the environment of a program giving each of its
+//instances a port has the first one
+func validPortPerProc(env []string) bool {
+	for _, v := range env {
+		if strings.HasPrefix(v, "PORT=") {
+			_, err := strconv.Atoi(strings.TrimPrefix(v, "PORT="))
+			return err == nil
+		}
+	}
+	return false
+}
+
+//parseDotenv parses KEY=value lines, where values may be quoted, and lines
+//may start with export
+func parseDotenv(content []byte) ([]string, error) {
+	env := []string{}
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", i+1)
+		}
+		key, value := strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:])
+		if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+			end := strings.LastIndexByte(value, value[0])
+			if end == 0 {
+				return nil, fmt.Errorf("line %d: unterminated quote", i+1)
+			}
+			quoted := value[1:end]
+			if value[0] == '"' {
+				quoted = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(quoted)
+			}
+			value = quoted
+		} else if comment := strings.Index(value, " #"); comment >= 0 {
+			value = strings.TrimSpace(value[:comment])
+		}
+		env = append(env, key+"="+value)
+	}
+	return env, nil
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const procfile = `# foreman style
+web: bundle exec rails server -p $PORT
+worker:   bundle exec sidekiq
+clock: bin/clock
+not a process
+`
+
+func TestProcfileToJSON(t *testing.T) {
+	env := []string{"RAILS_ENV=development", "PORT=3000"}
+	converted, _, warnings, err := ProcfileToJSON([]byte(procfile), "/app", env, map[string]int{"worker": 2, "clock": 0})
+	assert.Nil(t, err)
+	var config struct{ ProgList []Process }
+	assert.Nil(t, json.Unmarshal(converted, &config))
+	assert.Equal(t, 2, len(config.ProgList))
+
+	web := config.ProgList[0]
+	assert.Equal(t, "web", web.Name)
+	assert.Equal(t, "bundle exec rails server -p $PORT", web.Command)
+	assert.True(t, web.Shell)
+	assert.True(t, web.AutoStart)
+	assert.True(t, web.InheritEnv)
+	assert.Equal(t, "/app", web.WorkingDir)
+	assert.Equal(t, uint(1), web.NumProcs)
+	assert.Equal(t, []string{"RAILS_ENV=development", "PORT=3000"}, web.Env)
+
+	//each process of a type has its own port
+	worker := config.ProgList[1]
+	assert.Equal(t, "worker", worker.Name)
+	assert.Equal(t, "bundle exec sidekiq", worker.Command)
+	assert.Equal(t, uint(2), worker.NumProcs)
+	assert.True(t, worker.PortPerProc)
+	assert.Equal(t, []string{"RAILS_ENV=development", "PORT=3100"}, worker.Env)
+	for i := uint(0); i < 2; i++ {
+		assert.Equal(t, []string{"RAILS_ENV=development", "PORT=" + strconv.Itoa(3100+int(i))},
+			InstanceEnv(worker.Env, worker.PortPerProc, i))
+	}
+
+	assert.Equal(t, []string{"line 5: expected name: command, ignored"}, warnings)
+
+	_, _, _, err = ProcfileToJSON([]byte("web: a\nweb: b\n"), "/app", nil, nil)
+	assert.EqualError(t, err, "line 2: duplicate process type web")
+}
+
+func TestParseFormation(t *testing.T) {
+	formation, err := parseFormation("all=2, web=1,worker=0")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"all": 2, "web": 1, "worker": 0}, formation)
+	_, err = parseFormation("web")
+	assert.NotNil(t, err)
+	_, err = parseFormation("web=-1")
+	assert.NotNil(t, err)
+}
+
+func TestReadProcfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskmaster")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "Procfile.dev"), []byte("web: ./server\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("# comment\nexport A=1\nB=\"two\\nlines\"\nC='$raw' \nD=x # comment\n"), 0644)
+
+	converted, _, _, err := ReadConfig(filepath.Join(dir, "Procfile.dev"), ProcfileOptions{Env: DflProcfileEnv})
+	assert.Nil(t, err)
+	var config struct{ ProgList []Process }
+	assert.Nil(t, json.Unmarshal(converted, &config))
+	assert.Equal(t, []string{"A=1", "B=two\nlines", "C=$raw", "D=x", "PORT=5000"}, config.ProgList[0].Env)
+	assert.Equal(t, dir, config.ProgList[0].WorkingDir)
+
+	converted, _, _, err = ReadConfig(filepath.Join(dir, "Procfile.dev"), ProcfileOptions{Formation: "web=2"})
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(converted, &config))
+	assert.Equal(t, uint(2), config.ProgList[0].NumProcs)
+	assert.Equal(t, []string{"PORT=5001"}, InstanceEnv(config.ProgList[0].Env, true, 1))
+	_, _, _, err = ReadConfig(filepath.Join(dir, "Procfile.dev"), ProcfileOptions{Formation: "web"})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, filepath.Join(dir, ".env"), ProcfileOptions{Env: DflProcfileEnv}.EnvPath(filepath.Join(dir, "Procfile.dev")))
+	assert.Equal(t, "/etc/app.env", ProcfileOptions{Env: "/etc/app.env"}.EnvPath(filepath.Join(dir, "Procfile.dev")))
+	assert.Equal(t, "", ProcfileOptions{}.EnvPath(filepath.Join(dir, "Procfile.dev")))
+}
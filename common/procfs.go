@@ -0,0 +1,182 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//Number of clock ticks per second used by /proc (USER_HZ)
+const ClockTicks = 100
+
+//Usage is a resource sample of a process and all its descendants
+type Usage struct {
+	CPUTicks uint64
+	RSS      uint64
+	Threads  int
+	FDs      int
+}
+
+//ProcStat holds the fields of /proc/<pid>/stat used by taskmaster
+type ProcStat struct {
+	Pid       int
+	Ppid      int
+	State     byte
+	Utime     uint64
+	Stime     uint64
+	StartTime uint64
+}
+
+//parseProcStat parses the content of /proc/<pid>/stat. The command name
+//may contain spaces and parentheses, so fields are read after the last ')'
+func parseProcStat(line string) (ProcStat, error) {
+	var st ProcStat
+	open := strings.IndexByte(line, '(')
+	end := strings.LastIndexByte(line, ')')
+	if open < 0 || end < open {
+		return st, fmt.Errorf("Malformed stat line: %q", line)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+	if err != nil {
+		return st, err
+	}
+	rest := strings.Fields(line[end+1:])
+	if len(rest) < 20 {
+		return st, fmt.Errorf("Malformed stat line: %q", line)
+	}
+	st.Pid = pid
+	st.State = rest[0][0]
+	if st.Ppid, err = strconv.Atoi(rest[1]); err != nil {
+		return st, err
+	}
+	if st.Utime, err = strconv.ParseUint(rest[11], 10, 64); err != nil {
+		return st, err
+	}
+	if st.Stime, err = strconv.ParseUint(rest[12], 10, 64); err != nil {
+		return st, err
+	}
+	if st.StartTime, err = strconv.ParseUint(rest[19], 10, 64); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+func readProcStat(pid int) (ProcStat, error) {
+	content, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return ProcStat{}, err
+	}
+	return parseProcStat(string(content))
+}
+
+//readProcStatus returns the resident set size (in kB) and the thread count
+//of a process from /proc/<pid>/status
+func readProcStatus(pid int) (rss uint64, threads int, err error) {
+	file, err := os.Open("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		f := strings.Fields(scanner.Text())
+		if len(f) < 2 {
+			continue
+		}
+		switch f[0] {
+		case "VmRSS:":
+			rss, _ = strconv.ParseUint(f[1], 10, 64)
+		case "Threads:":
+			threads, _ = strconv.Atoi(f[1])
+		}
+	}
+	return rss, threads, scanner.Err()
+}
+
+func countFds(pid int) int {
+	fds, err := ioutil.ReadDir("/proc/" + strconv.Itoa(pid) + "/fd")
+	if err != nil {
+		return 0
+	}
+	return len(fds)
+}
+
+//AllProcStats reads the stat file of every process of the system
+func AllProcStats() []ProcStat {
+	var stats []ProcStat
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if st, err := readProcStat(pid); err == nil {
+			stats = append(stats, st)
+		}
+	}
+	return stats
+}
+
+//Descendants returns root and the pids of all its descendants
+func Descendants(root int) []int {
+	return DescendantsIn(AllProcStats(), root)
+}
+
+//DescendantsIn is like Descendants, using an existing snapshot of /proc
+func DescendantsIn(stats []ProcStat, root int) []int {
+	children := make(map[int][]int)
+	for _, st := range stats {
+		children[st.Ppid] = append(children[st.Ppid], st.Pid)
+	}
+	tree := []int{root}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	return tree
+}
+
+//SampleTree sums the resource usage of a process and its descendants, and
+//returns the pids of the tree
+func SampleTree(root int) (Usage, []int, error) {
+	var u Usage
+	if _, err := readProcStat(root); err != nil {
+		return u, nil, err
+	}
+	tree := Descendants(root)
+	for _, pid := range tree {
+		st, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		rss, threads, err := readProcStatus(pid)
+		if err != nil {
+			continue
+		}
+		u.CPUTicks += st.Utime + st.Stime
+		u.RSS += rss
+		u.Threads += threads
+		u.FDs += countFds(pid)
+	}
+	return u, tree, nil
+}
+
+//ProcessNameOf returns the program a process was spawned for, as exported in
+//its environment by the server
+func ProcessNameOf(pid int) string {
+	content, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/environ")
+	if err != nil {
+		return ""
+	}
+	for _, v := range strings.Split(string(content), "\x00") {
+		if strings.HasPrefix(v, EnvProcessName+"=") {
+			return v[len(EnvProcessName)+1:]
+		}
+	}
+	return ""
+}
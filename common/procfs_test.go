@@ -0,0 +1,31 @@
+package common
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProcStat(t *testing.T) {
+	line := "4242 (my (weird) prog) S 1 4242 4242 0 -1 4194560 250 0 0 0 17 5 0 0 20 0 3 0 123456 10000 200 18446744073709551615"
+	st, err := parseProcStat(line)
+	assert.Nil(t, err)
+	assert.Equal(t, 4242, st.Pid)
+	assert.Equal(t, 1, st.Ppid)
+	assert.Equal(t, byte('S'), st.State)
+	assert.Equal(t, uint64(17), st.Utime)
+	assert.Equal(t, uint64(5), st.Stime)
+	assert.Equal(t, uint64(123456), st.StartTime)
+	_, err = parseProcStat("4242 (truncated) S 1")
+	assert.NotNil(t, err)
+}
+
+func TestSampleTree(t *testing.T) {
+	u, tree, err := SampleTree(os.Getpid())
+	assert.Nil(t, err)
+	assert.Equal(t, os.Getpid(), tree[0])
+	assert.True(t, u.RSS > 0)
+	assert.True(t, u.Threads > 0)
+	assert.True(t, u.FDs > 0)
+}
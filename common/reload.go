@@ -0,0 +1,67 @@
+package common
+
+import (
+	"fmt"
+	"syscall"
+)
+
+//Options which programs able to reload themselves may get without a restart
+var reloadableOptions = []string{"Env"}
+
+func validReloadable(options []string) bool {
+	for _, option := range options {
+		valid := false
+		for _, o := range reloadableOptions {
+			valid = valid || o == option
+		}
+		if !valid {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Process) GetReloadSignal() syscall.Signal {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.ReloadSignal
+}
+func (p *Process) GetReloadCommand() string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.ReloadCommand
+}
+
+//CanReload tells if the process has a way to reload itself
+func (p *Process) CanReload() bool {
+	return p.GetReloadSignal() != 0 || p.GetReloadCommand() != ""
+}
+
+//Reload asks the running process to reload itself, with its reload command
+//if it has one, or else its reload signal
+func (p *Process) Reload() error {
+	pid := p.GetPid()
+	if pid <= 0 {
+		return fmt.Errorf("Process %s is not running", p.GetName())
+	}
+	if p.GetReloadCommand() != "" {
+		return p.RunHook(HookReload, pid, 0)
+	}
+	if sig := p.GetReloadSignal(); sig != 0 {
+		return syscall.Kill(pid, sig)
+	}
+	return fmt.Errorf("Process %s has no ReloadSignal or ReloadCommand", p.GetName())
+}
+
+//ApplyReloadable copies the reloadable options of new into the process
+func (p *Process) ApplyReloadable(new *Process) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	for _, option := range new.Reloadable {
+		switch option {
+		case "Env":
+			p.Env = new.Env
+			p.InheritEnv = new.InheritEnv
+		}
+	}
+}
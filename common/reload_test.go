@@ -0,0 +1,25 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyReloadable(t *testing.T) {
+	assert.True(t, validReloadable([]string{"Env"}))
+	assert.False(t, validReloadable([]string{"Command"}))
+	assert.False(t, validReloadable([]string{"WorkingDir"}))
+	assert.False(t, validReloadable([]string{"Umask"}))
+
+	p, new := NewProc(), NewProc()
+	p.Env = []string{"A=1"}
+	new.Env = []string{"A=2"}
+	new.Umask = 077
+	new.Reloadable = []string{"Env"}
+	p.ApplyReloadable(&new)
+	assert.Equal(t, []string{"A=2"}, p.Env)
+	assert.Equal(t, DflUmask, p.Umask)
+	assert.False(t, p.CanReload())
+	assert.NotNil(t, p.Reload())
+}
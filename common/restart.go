@@ -0,0 +1,46 @@
+package common
+
+import (
+	"time"
+)
+
+func (p *Process) GetRestartLimit() uint {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.RestartLimit
+}
+func (p *Process) GetRestartWindow() uint {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.RestartWindow
+}
+
+//AddRestart records a restart happening at t and returns the number of
+//restarts which happened during the last RestartWindow seconds
+func (p *Process) AddRestart(t time.Time) int {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	since := t.Add(-time.Duration(p.RestartWindow) * time.Second)
+	restarts := []time.Time{}
+	for _, r := range p.Restarts {
+		if r.After(since) {
+			restarts = append(restarts, r)
+		}
+	}
+	p.Restarts = append(restarts, t)
+	return len(p.Restarts)
+}
+
+func (p *Process) ClearRestarts() {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.Restarts = nil
+}
+
+//IsCrashLooping records a restart and tells if the process has been
+//restarted more than RestartLimit times in its restart window
+func (p *Process) IsCrashLooping() bool {
+	limit := p.GetRestartLimit()
+	n := p.AddRestart(time.Now())
+	return limit > 0 && uint(n) > limit
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddRestart(t *testing.T) {
+	p := NewProc()
+	p.RestartWindow = 10
+	now := time.Now()
+	assert.Equal(t, 1, p.AddRestart(now.Add(-5*time.Second)))
+	assert.Equal(t, 2, p.AddRestart(now))
+	//the first restarts are out of the window
+	assert.Equal(t, 1, p.AddRestart(now.Add(20*time.Second)))
+	p.ClearRestarts()
+	assert.Equal(t, 1, p.AddRestart(now))
+}
+
+func TestIsCrashLooping(t *testing.T) {
+	p := NewProc()
+	for i := 0; i < 5; i++ {
+		assert.False(t, p.IsCrashLooping())
+	}
+	p.RestartLimit = 2
+	p.ClearRestarts()
+	assert.False(t, p.IsCrashLooping())
+	assert.False(t, p.IsCrashLooping())
+	assert.True(t, p.IsCrashLooping())
+}
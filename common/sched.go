@@ -0,0 +1,162 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	IOClassRealtime   = "realtime"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+	maxCPUs           = 1024
+)
+
+var ioClasses = map[string]uintptr{
+	IOClassRealtime:   1,
+	IOClassBestEffort: 2,
+	IOClassIdle:       3,
+}
+
+//Sched gathers the scheduling settings of a process
+type Sched struct {
+	Nice           int
+	IOClass        string
+	IOPriority     int
+	CPUAffinity    []int
+	OOMScoreAdjust int
+}
+
+func validSched(s Sched) bool {
+	if _, exists := ioClasses[s.IOClass]; s.IOClass != "" && !exists {
+		return false
+	}
+	for _, cpu := range s.CPUAffinity {
+		if cpu < 0 || cpu >= maxCPUs {
+			return false
+		}
+	}
+	return s.Nice >= -20 && s.Nice <= 19 && s.IOPriority >= 0 && s.IOPriority <= 7 &&
+		s.OOMScoreAdjust >= -1000 && s.OOMScoreAdjust <= 1000
+}
+
+func (s Sched) Equal(o Sched) bool {
+	if len(s.CPUAffinity) != len(o.CPUAffinity) {
+		return false
+	}
+	for i := range s.CPUAffinity {
+		if s.CPUAffinity[i] != o.CPUAffinity[i] {
+			return false
+		}
+	}
+	return s.Nice == o.Nice && s.IOClass == o.IOClass && s.IOPriority == o.IOPriority &&
+		s.OOMScoreAdjust == o.OOMScoreAdjust
+}
+
+func (p *Process) GetSched() Sched {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return Sched{
+		Nice:           p.Nice,
+		IOClass:        p.IOClass,
+		IOPriority:     p.IOPriority,
+		CPUAffinity:    p.CPUAffinity,
+		OOMScoreAdjust: p.OOMScoreAdjust,
+	}
+}
+
+//ApplySched applies the scheduling settings to every thread of the running
+//process, e.g. after they changed in the config
+func (p *Process) ApplySched() error {
+	pid := p.GetPid()
+	if pid <= 0 {
+		return nil
+	}
+	s := p.GetSched()
+	tasks, err := ioutil.ReadDir("/proc/" + strconv.Itoa(pid) + "/task")
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if err := s.setNice(tid); err != nil {
+			return err
+		}
+		if err := s.applyThread(tid, true); err != nil {
+			return err
+		}
+	}
+	return s.applyOOM(pid)
+}
+
+//apply applies the settings to the calling thread, whose attributes are
+//inherited by the program it executes
+func (s Sched) apply() error {
+	if s.Nice != 0 {
+		if err := s.setNice(0); err != nil {
+			return err
+		}
+	}
+	if err := s.applyThread(0, false); err != nil {
+		return err
+	}
+	if s.OOMScoreAdjust != 0 {
+		return s.applyOOM(syscall.Getpid())
+	}
+	return nil
+}
+
+//setNice sets the nice value of a thread, which is per thread on Linux
+func (s Sched) setNice(tid int) error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, s.Nice); err != nil {
+		return fmt.Errorf("unable to set nice value %d: %s", s.Nice, err)
+	}
+	return nil
+}
+
+//applyThread sets the io priority and cpu affinity of a thread. With reset,
+//the settings missing from the config are put back to their default, for a
+//thread which may have been given others before
+func (s Sched) applyThread(tid int, reset bool) error {
+	if s.IOClass != "" || reset {
+		//IOPRIO_WHO_PROCESS, class in the 3 high bits of the priority. No
+		//class is best-effort at the level given by the nice value
+		value := ioClasses[s.IOClass]<<13 | uintptr(s.IOPriority)
+		_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, 1, uintptr(tid), value)
+		if errno != 0 {
+			return fmt.Errorf("unable to set io priority %s/%d: %s", s.IOClass, s.IOPriority, errno)
+		}
+	}
+	if len(s.CPUAffinity) > 0 || reset {
+		var mask [maxCPUs / 64]uint64
+		for _, cpu := range s.CPUAffinity {
+			mask[cpu/64] |= 1 << (uint(cpu) % 64)
+		}
+		if len(s.CPUAffinity) == 0 {
+			//every cpu, the kernel keeps the ones which exist
+			for i := range mask {
+				mask[i] = ^uint64(0)
+			}
+		}
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(tid),
+			unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+		if errno != 0 {
+			return fmt.Errorf("unable to set cpu affinity %v: %s", s.CPUAffinity, errno)
+		}
+	}
+	return nil
+}
+
+func (s Sched) applyOOM(pid int) error {
+	path := "/proc/" + strconv.Itoa(pid) + "/oom_score_adj"
+	if err := ioutil.WriteFile(path, []byte(strconv.Itoa(s.OOMScoreAdjust)), 0644); err != nil {
+		return fmt.Errorf("unable to set oom score adjustment %d: %s", s.OOMScoreAdjust, err)
+	}
+	return nil
+}
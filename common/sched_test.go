@@ -0,0 +1,48 @@
+package common
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidSched(t *testing.T) {
+	assert.True(t, validSched(Sched{}))
+	assert.True(t, validSched(Sched{Nice: 10, IOClass: IOClassIdle, CPUAffinity: []int{0, 3}, OOMScoreAdjust: -500}))
+	assert.False(t, validSched(Sched{Nice: 20}))
+	assert.False(t, validSched(Sched{IOClass: "fast"}))
+	assert.False(t, validSched(Sched{IOClass: IOClassBestEffort, IOPriority: 8}))
+	assert.False(t, validSched(Sched{CPUAffinity: []int{-1}}))
+	assert.False(t, validSched(Sched{OOMScoreAdjust: 1001}))
+	assert.True(t, Sched{CPUAffinity: []int{1}}.Equal(Sched{CPUAffinity: []int{1}}))
+	assert.False(t, Sched{CPUAffinity: []int{1}}.Equal(Sched{CPUAffinity: []int{2}}))
+}
+
+func TestApplyThreadReset(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	assert.Nil(t, cmd.Start())
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	pid := cmd.Process.Pid
+	ioprio := func() uintptr {
+		value, _, _ := syscall.Syscall(syscall.SYS_IOPRIO_GET, 1, uintptr(pid), 0)
+		return value
+	}
+	affinity := func() uint64 {
+		var mask [maxCPUs / 64]uint64
+		syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, uintptr(pid), unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+		return mask[0]
+	}
+	all := affinity()
+	assert.Nil(t, Sched{IOClass: IOClassIdle, CPUAffinity: []int{0}}.applyThread(pid, false))
+	assert.Equal(t, ioClasses[IOClassIdle]<<13, ioprio())
+	assert.Equal(t, uint64(1), affinity())
+	assert.Nil(t, Sched{}.applyThread(pid, false))
+	assert.Equal(t, ioClasses[IOClassIdle]<<13, ioprio())
+	assert.Nil(t, Sched{}.applyThread(pid, true))
+	assert.Equal(t, all, affinity())
+	assert.NotEqual(t, ioClasses[IOClassIdle]<<13, ioprio())
+}
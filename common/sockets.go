@@ -0,0 +1,142 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+//Listening sockets are owned by the server and shared by address, so that
+//they stay open across restarts and reloads of the programs using them
+var (
+	socketsLock = new(sync.Mutex)
+	sockets     = make(map[string]*os.File)
+)
+
+//parseSocket splits a socket address such as tcp://:8080 or
+//unix:///run/app.sock into a network and an address
+func parseSocket(address string) (network, addr string, err error) {
+	spl := strings.SplitN(address, "://", 2)
+	if len(spl) != 2 || spl[1] == "" {
+		return "", "", errors.New(fmt.Sprintf("Invalid socket address: %s", address))
+	}
+	switch spl[0] {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return spl[0], spl[1], nil
+	}
+	return "", "", errors.New(fmt.Sprintf("Unsupported socket type: %s", address))
+}
+
+func validSockets(addresses []string) bool {
+	for _, address := range addresses {
+		if _, _, err := parseSocket(address); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+//ListenSocket returns the listening socket bound to address, binding it the
+//first time it is requested
+func ListenSocket(address string) (*os.File, error) {
+	socketsLock.Lock()
+	defer socketsLock.Unlock()
+	if file, exists := sockets[address]; exists {
+		return file, nil
+	}
+	network, addr, err := parseSocket(address)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		os.Remove(addr)
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if unix, ok := listener.(*net.UnixListener); ok {
+		unix.SetUnlinkOnClose(false)
+	}
+	file, err := listener.(interface {
+		File() (*os.File, error)
+	}).File()
+	listener.Close()
+	if err != nil {
+		return nil, err
+	}
+	sockets[address] = file
+	return file, nil
+}
+
+//Sockets returns the sockets currently bound, by address
+func Sockets() map[string]*os.File {
+	socketsLock.Lock()
+	defer socketsLock.Unlock()
+	res := make(map[string]*os.File, len(sockets))
+	for address, file := range sockets {
+		res[address] = file
+	}
+	return res
+}
+
+//InheritSocket registers a socket passed by a previous server
+func InheritSocket(address string, file *os.File) {
+	socketsLock.Lock()
+	defer socketsLock.Unlock()
+	sockets[address] = file
+}
+
+//CloseUnusedSockets closes the sockets no program uses anymore
+func CloseUnusedSockets(used map[string]bool) {
+	socketsLock.Lock()
+	defer socketsLock.Unlock()
+	for address, file := range sockets {
+		if !used[address] {
+			file.Close()
+			delete(sockets, address)
+			if network, addr, _ := parseSocket(address); network == "unix" {
+				os.Remove(addr)
+			}
+		}
+	}
+}
+
+//WaitReadable waits at most timeout for fd to be readable
+func WaitReadable(fd int, timeout time.Duration) (bool, error) {
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout/time.Millisecond))
+	return n > 0, err
+}
+
+//initSockets passes the sockets of the process as file descriptors 3 and
+//onwards, with the LISTEN_FDS and LISTEN_PID variables of socket activation.
+//LISTEN_PID must be the pid of the program itself, so it is set by the
+//launcher, or by a shell without launcher, right before executing the command
+func (p *Process) initSockets() error {
+	for _, address := range p.GetSockets() {
+		file, err := ListenSocket(address)
+		if err != nil {
+			return err
+		}
+		p.Cmd.ExtraFiles = append(p.Cmd.ExtraFiles, file)
+	}
+	if len(p.Cmd.ExtraFiles) == 0 {
+		return nil
+	}
+	p.Cmd.Env = append(p.Cmd.Env, "LISTEN_FDS="+strconv.Itoa(len(p.Cmd.ExtraFiles)))
+	if LauncherPath != "" {
+		return nil
+	}
+	args := append([]string{"/bin/sh", "-c", `LISTEN_PID=$$; export LISTEN_PID; exec "$0" "$@"`}, p.Cmd.Args...)
+	p.Cmd.Path = "/bin/sh"
+	p.Cmd.Args = args
+	return nil
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitReadableHighFd(t *testing.T) {
+	var limit syscall.Rlimit
+	syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit)
+	if limit.Cur < 2048 {
+		t.Skip("not enough file descriptors")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	//a busy server easily has descriptors past the reach of select
+	fd := 2000
+	if err := syscall.Dup3(int(r.Fd()), fd, syscall.O_CLOEXEC); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fd)
+	ready, err := WaitReadable(fd, 10*time.Millisecond)
+	assert.Nil(t, err)
+	assert.False(t, ready)
+	w.Write([]byte("x"))
+	ready, err = WaitReadable(fd, time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ready)
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"os/exec"
+	"sync"
+)
+
+//Every child started by the server is registered here, so that a central
+//reaper can tell its own children from adopted orphans
+var (
+	spawnLock   = new(sync.RWMutex)
+	spawnedLock = new(sync.Mutex)
+	spawned     = make(map[int]bool)
+)
+
+//StartCmd starts cmd and registers its pid as a child of the server
+func StartCmd(cmd *exec.Cmd) error {
+	spawnLock.RLock()
+	defer spawnLock.RUnlock()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	spawnedLock.Lock()
+	spawned[cmd.Process.Pid] = true
+	spawnedLock.Unlock()
+	return nil
+}
+
+//WaitCmd waits for a command started with StartCmd and unregisters it
+func WaitCmd(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	releaseChild(cmd.Process.Pid)
+	return err
+}
+
+//AdoptChild registers a child which was not started with StartCmd, such as
+//a program kept across an in-place upgrade of the server
+func AdoptChild(pid int) {
+	spawnedLock.Lock()
+	spawned[pid] = true
+	spawnedLock.Unlock()
+}
+
+func releaseChild(pid int) {
+	spawnedLock.Lock()
+	delete(spawned, pid)
+	spawnedLock.Unlock()
+}
+
+func IsSpawned(pid int) bool {
+	spawnedLock.Lock()
+	defer spawnedLock.Unlock()
+	return spawned[pid]
+}
+
+//LockSpawns prevents new children from being started until UnlockSpawns
+func LockSpawns() {
+	spawnLock.Lock()
+}
+
+func UnlockSpawns() {
+	spawnLock.Unlock()
+}
@@ -0,0 +1,203 @@
+package common
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const sysPidfdOpen = 434
+
+//ProcState is the runtime state of a process persisted by the server, used
+//to re-adopt the programs which survived a restart of the server
+type ProcState struct {
+	Name       string
+	Pid        int
+	State      string
+	Runtime    time.Time
+	StartTicks uint64
+	ConfigHash string
+	Stdout     int
+	Stderr     int
+	History    []ExitRecord
+	ManualStop bool
+}
+
+//InheritedLogs returns the log files passed as file descriptors by a server
+//being upgraded
+func (state ProcState) InheritedLogs() (stdout, stderr *os.File) {
+	if state.Stdout != 0 {
+		stdout = InheritedFile(state.Stdout, state.Name+" stdout")
+	}
+	if state.Stderr != 0 {
+		stderr = InheritedFile(state.Stderr, state.Name+" stderr")
+	}
+	return
+}
+
+//InheritedFile returns a file descriptor kept across the exec of an upgrade,
+//which must not leak into the programs started from now on
+func InheritedFile(fd int, name string) *os.File {
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), name)
+}
+
+//StateHook, when set, is called after every state transition of a process
+var StateHook func(p *Process)
+
+//ConfigHash identifies the part of the configuration that requires a restart
+//when it changes, the options compared by the server on reload
+func (p *Process) ConfigHash() string {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	env := make([]string, len(p.Env))
+	copy(env, p.Env)
+	sort.Strings(env)
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%q %q %t %q %q %q %o %q %q", p.Command, p.Type, p.Shell, p.Outfile, p.Errfile,
+		p.WorkingDir, p.Umask, p.User, p.Group)
+	//maps are printed sorted by key
+	fmt.Fprintf(hash, " %v %q %t %q %q", p.Rlimits, p.Namespaces, p.PrivateTmp, p.ReadOnlyPaths, p.Chroot)
+	fmt.Fprintf(hash, " %q %t %t %q", env, p.InheritEnv, p.Notify, p.Sockets)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+//GetProcState returns the state of the process to persist
+func (p *Process) GetProcState() ProcState {
+	status := p.GetProcStatus()
+	state := ProcState{
+		Name:       status.Name,
+		Pid:        status.Pid,
+		State:      status.State,
+		Runtime:    status.Runtime,
+		ConfigHash: p.ConfigHash(),
+		History:    p.GetHistory(),
+		ManualStop: p.GetManualStop(),
+	}
+	if state.Pid != 0 {
+		state.StartTicks, _ = StartTicksOf(state.Pid)
+	}
+	return state
+}
+
+//StartTicksOf returns the start time of a process, in clock ticks after boot
+func StartTicksOf(pid int) (uint64, error) {
+	st, err := readProcStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	return st.StartTime, nil
+}
+
+//CanAdopt tells if the process described by a saved state is still alive and
+//still runs this configuration
+func (p *Process) CanAdopt(state ProcState) bool {
+	if state.Pid == 0 || (state.State != Running && state.State != Starting) {
+		return false
+	}
+	if state.ConfigHash != p.ConfigHash() {
+		return false
+	}
+	st, err := readProcStat(state.Pid)
+	if err != nil || st.StartTime != state.StartTicks {
+		return false
+	}
+	//a child kept across an in-place upgrade can still be waited for once dead
+	return st.State != 'Z' || st.Ppid == os.Getpid()
+}
+
+//Adopt makes the next Start watch an already running pid instead of
+//spawning a new command
+func (p *Process) Adopt(state ProcState) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.Adopted = state.Pid
+	p.Runtime = state.Runtime
+}
+
+func (p *Process) GetAdopted() int {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return p.Adopted
+}
+
+//watchAdopted waits for an adopted process to exit. Its exit status is only
+//known when it is still a child of the server, after an in-place upgrade
+func (p *Process) watchAdopted(started, processEnd chan bool) {
+	p.Lock.Lock()
+	pid := p.Adopted
+	start := p.Runtime
+	p.Adopted = 0
+	p.Pid = pid
+	p.Cmd = nil
+	p.Lock.Unlock()
+	st, err := readProcStat(pid)
+	child := err == nil && st.Ppid == os.Getpid()
+	if child {
+		AdoptChild(pid)
+	}
+	p.SetStopReason("")
+	started <- true
+	var cmd *exec.Cmd
+	if child {
+		process, _ := os.FindProcess(pid)
+		if state, err := process.Wait(); err == nil {
+			cmd = &exec.Cmd{Process: process, ProcessState: state}
+		}
+		releaseChild(pid)
+	} else {
+		WaitPid(pid)
+	}
+	p.Lock.Lock()
+	p.Cmd = cmd
+	p.Lock.Unlock()
+	record := ExitRecord{
+		Pid:      pid,
+		Start:    start,
+		End:      time.Now(),
+		ExitCode: p.GetExitCode(),
+		Reason:   p.exitReason(),
+	}
+	if cmd != nil {
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			record.Signal = status.Signal()
+			record.CoreDumped = status.CoreDump()
+		}
+	}
+	p.AddExitRecord(record)
+	p.SetPid(0)
+	processEnd <- true
+}
+
+//WaitPid blocks until a process which is not a child of the server exits.
+//It uses a pidfd when the kernel supports it, and polls otherwise
+func WaitPid(pid int) {
+	fd, _, errno := syscall.Syscall(sysPidfdOpen, uintptr(pid), 0, 0)
+	if errno == 0 {
+		defer syscall.Close(int(fd))
+		for {
+			ready, err := WaitReadable(int(fd), time.Hour)
+			if ready {
+				return
+			}
+			if err != nil && err != syscall.EINTR {
+				break
+			}
+		}
+	}
+	ticks, _ := StartTicksOf(pid)
+	for {
+		if now, err := StartTicksOf(pid); err != nil || now != ticks {
+			return
+		}
+		if _, err := os.Stat("/proc/" + strconv.Itoa(pid)); err != nil {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasCorrectlyExitAdopted(t *testing.T) {
+	p := NewProc()
+	p.ExitCodes = []int{0}
+	//an adopted run has no exit status, it must not look unexpected
+	assert.Equal(t, ExitUnknown, p.GetExitCode())
+	assert.True(t, p.HasCorrectlyExit())
+}
+
+func TestConfigHash(t *testing.T) {
+	p := NewProc()
+	p.Command = "/bin/sleep 100"
+	p.Env = []string{"A=1", "B=2"}
+	hash := p.ConfigHash()
+	p.Env = []string{"B=2", "A=1"}
+	assert.Equal(t, hash, p.ConfigHash())
+	for _, change := range []func(p *Process){
+		func(p *Process) { p.User = "nobody" },
+		func(p *Process) { p.Shell = true },
+		func(p *Process) { p.InheritEnv = true },
+		func(p *Process) { p.Namespaces = []string{"pid"} },
+		func(p *Process) { p.Chroot = "/srv" },
+		func(p *Process) { p.Rlimits = map[string]uint64{"nofile": 1024} },
+		func(p *Process) { p.Sockets = []string{"tcp:8080"} },
+		func(p *Process) { p.Notify = true },
+	} {
+		changed := NewProc()
+		changed.Command, changed.Env = p.Command, p.Env
+		change(&changed)
+		assert.NotEqual(t, hash, changed.ConfigHash())
+	}
+}
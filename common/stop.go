@@ -0,0 +1,50 @@
+package common
+
+import (
+	"syscall"
+)
+
+//StopStep is one step of the stop sequence of a process: the signal to send,
+//then how many seconds to wait for the process to exit
+type StopStep struct {
+	Signal syscall.Signal
+	Wait   uint
+}
+
+//Time given to a process to die after the final SIGKILL
+const DflKillWait uint = 5
+
+func (p *Process) GetStopSequence() []StopStep {
+	p.Lock.RLock()
+	defer p.Lock.RUnlock()
+	return stopSequence(p.StopSequence, p.StopSignal, p.StopTime)
+}
+
+//stopSequence returns the configured sequence, or StopSignal then StopTime
+//when there is none. The final step is always SIGKILL
+func stopSequence(steps []StopStep, signal syscall.Signal, wait uint) []StopStep {
+	var seq []StopStep
+	if len(steps) == 0 {
+		seq = []StopStep{{Signal: signal, Wait: wait}}
+	} else {
+		seq = make([]StopStep, len(steps))
+		copy(seq, steps)
+	}
+	if seq[len(seq)-1].Signal != syscall.SIGKILL {
+		seq = append(seq, StopStep{Signal: syscall.SIGKILL})
+	}
+	last := &seq[len(seq)-1]
+	if last.Wait < DflKillWait {
+		last.Wait = DflKillWait
+	}
+	return seq
+}
+
+func validStopSequence(steps []StopStep) bool {
+	for _, step := range steps {
+		if step.Signal <= 0 || step.Signal >= 65 {
+			return false
+		}
+	}
+	return true
+}
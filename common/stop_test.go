@@ -0,0 +1,23 @@
+package common
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopSequence(t *testing.T) {
+	seq := stopSequence(nil, syscall.SIGTERM, 10)
+	assert.Equal(t, []StopStep{{syscall.SIGTERM, 10}, {syscall.SIGKILL, DflKillWait}}, seq)
+
+	steps := []StopStep{{syscall.SIGINT, 10}, {syscall.SIGTERM, 10}, {syscall.SIGQUIT, 5}}
+	seq = stopSequence(steps, syscall.SIGTERM, 10)
+	assert.Equal(t, 4, len(seq))
+	assert.Equal(t, syscall.SIGQUIT, seq[2].Signal)
+	assert.Equal(t, syscall.SIGKILL, seq[3].Signal)
+	assert.Equal(t, 3, len(steps))
+
+	seq = stopSequence([]StopStep{{syscall.SIGKILL, 0}}, syscall.SIGTERM, 10)
+	assert.Equal(t, []StopStep{{syscall.SIGKILL, DflKillWait}}, seq)
+}
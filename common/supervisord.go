@@ -0,0 +1,392 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//iniKey is a key of an INI section, with the position of its value
+type iniKey struct {
+	name   string
+	value  string
+	line   int
+	column int
+}
+
+type iniSection struct {
+	name string
+	line int
+	keys []iniKey
+}
+
+//parseINI reads an INI file the way the python ConfigParser used by
+//supervisord does: keys are lowercased, values may be continued on indented
+//lines, and comments start with ; or #, after a space when inline
+func parseINI(content []byte) ([]iniSection, error) {
+	var sections []iniSection
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == ';' || trimmed[0] == '#' {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			//continuation of the previous value
+			if len(sections) == 0 || len(sections[len(sections)-1].keys) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected indentation", i+1)
+			}
+			keys := sections[len(sections)-1].keys
+			keys[len(keys)-1].value += "\n" + stripComment(trimmed)
+			continue
+		}
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("line %d: invalid section header", i+1)
+			}
+			sections = append(sections, iniSection{name: strings.TrimSpace(line[1 : len(line)-1]), line: i + 1})
+			continue
+		}
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		if len(sections) == 0 {
+			return nil, fmt.Errorf("line %d: key outside of a section", i+1)
+		}
+		value := strings.TrimLeft(line[sep+1:], " \t")
+		sections[len(sections)-1].keys = append(sections[len(sections)-1].keys, iniKey{
+			name:   strings.ToLower(strings.TrimSpace(line[:sep])),
+			value:  stripComment(value),
+			line:   i + 1,
+			column: len(line) - len(value) + 1,
+		})
+	}
+	return sections, nil
+}
+
+func stripComment(value string) string {
+	for i := 1; i < len(value); i++ {
+		if (value[i] == ';' || value[i] == '#') && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimRight(value[:i], " \t")
+		}
+	}
+	return value
+}
+
+//Sections of a supervisord config which only configure supervisord itself
+var supervisordControlSections = []string{"supervisord", "supervisorctl", "unix_http_server", "inet_http_server", "rpcinterface:"}
+
+//supervisordDefaults are the defaults of supervisord which differ from ours
+var supervisordDefaults = []struct {
+	name  string
+	value interface{}
+}{
+	{"AutoStart", true},
+	{"AutoRestart", Unexpected},
+	{"StartTime", 1},
+	{"StartRetries", 3},
+	{"ExitCodes", []int{0}},
+	{"StopSignal", syscall.SIGTERM},
+	{"StopTime", 10},
+}
+
+var supervisordSignals = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM,
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+var supervisordExpansion = regexp.MustCompile(`%\(([A-Za-z0-9_]+)\)s`)
+
+//supervisordOption is an option of a process converted from a supervisord
+//key, with the position it comes from
+type supervisordOption struct {
+	name  string
+	value interface{}
+	key   iniKey
+}
+
+//SupervisordToJSON converts the programs of a supervisord config to our
+//JSON config. here is the directory of the config, as %(here)s. Keys and
+//sections which cannot be converted are ignored with a warning
+func SupervisordToJSON(content []byte, here string) ([]byte, SourceMap, []string, error) {
+	sections, err := parseINI(content)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c := &jsonWriter{}
+	var warnings []string
+	c.buf.WriteString(`{"ProgList":[`)
+	programs := 0
+	for _, section := range sections {
+		if !strings.HasPrefix(section.name, "program:") {
+			if !isControlSection(section.name) {
+				warnings = append(warnings, fmt.Sprintf("line %d: section [%s] is not supported, ignored", section.line, section.name))
+			}
+			continue
+		}
+		name := strings.TrimSpace(strings.TrimPrefix(section.name, "program:"))
+		nums := processNums(section)
+		for _, num := range nums {
+			options, more, err := convertProgram(name, num, section, here)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if num == 0 {
+				warnings = append(warnings, more...)
+			}
+			if len(nums) > 1 {
+				//each instance is its own program, named like CreateMultiProcess would
+				for i := range options {
+					if options[i].name == "Name" {
+						options[i].value = name + strconv.Itoa(num)
+					} else if options[i].name == "NumProcs" {
+						options[i].value = 1
+					}
+				}
+			}
+			if programs > 0 {
+				c.buf.WriteByte(',')
+			}
+			programs++
+			c.buf.WriteByte('{')
+			for i, option := range options {
+				if i > 0 {
+					c.buf.WriteByte(',')
+				}
+				c.write(option.name, option.value, option.key.line, option.key.column)
+			}
+			c.buf.WriteByte('}')
+		}
+	}
+	c.buf.WriteString(`]}`)
+	return c.buf.Bytes(), c.position, warnings, nil
+}
+
+func isControlSection(name string) bool {
+	for _, control := range supervisordControlSections {
+		if name == control || (strings.HasSuffix(control, ":") && strings.HasPrefix(name, control)) {
+			return true
+		}
+	}
+	return false
+}
+
+//processNums returns the process numbers of the instances of a program. A
+//program using %(process_num)s is converted once per instance, since its
+//options differ between them
+func processNums(section iniSection) []int {
+	uses, n := false, uint64(1)
+	for _, key := range section.keys {
+		uses = uses || strings.Contains(key.value, "%(process_num)s")
+		if key.name == "numprocs" {
+			if v, err := strconv.ParseUint(key.value, 10, 32); err == nil && v > 1 {
+				n = v
+			}
+		}
+	}
+	if !uses {
+		n = 1
+	}
+	nums := make([]int, n)
+	for i := range nums {
+		nums[i] = i
+	}
+	return nums
+}
+
+//convertProgram converts a [program:x] section for the instance num of the
+//program. Options set by the section replace the defaults of supervisord
+func convertProgram(name string, num int, section iniSection, here string) ([]supervisordOption, []string, error) {
+	header := iniKey{line: section.line, column: 1}
+	options := []supervisordOption{{"Name", name, header}}
+	for _, dfl := range supervisordDefaults {
+		options = append(options, supervisordOption{dfl.name, dfl.value, header})
+	}
+	var warnings []string
+	vars := map[string]string{"here": here, "program_name": name, "group_name": name,
+		"process_num": strconv.Itoa(num)}
+	if hostname, err := os.Hostname(); err == nil {
+		vars["host_node_name"] = hostname
+	}
+	for _, key := range section.keys {
+		invalid := func(err error) error {
+			return fmt.Errorf("line %d, column %d: invalid %s of program %s: %s", key.line, key.column, key.name, name, err)
+		}
+		value, unknown := expandSupervisord(key.value, vars)
+		for _, v := range unknown {
+			warnings = append(warnings, fmt.Sprintf("line %d: %%(%s)s is not supported, kept as is", key.line, v))
+		}
+		add := func(option string, value interface{}) {
+			for i := range options {
+				if options[i].name == option {
+					options[i] = supervisordOption{option, value, key}
+					return
+				}
+			}
+			options = append(options, supervisordOption{option, value, key})
+		}
+		switch key.name {
+		case "command":
+			add("Command", value)
+			//supervisord splits the command itself, honouring quotes, while
+			//taskmaster splits it on whitespace: only a shell can run the
+			//commands which would be split differently, or use shell syntax
+			args, shell, err := SplitCommand(value)
+			if err != nil {
+				return nil, nil, invalid(err)
+			}
+			if shell || !reflect.DeepEqual(args, strings.Fields(value)) {
+				add("Shell", true)
+			}
+		case "numprocs", "startsecs", "startretries", "stopwaitsecs":
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, nil, invalid(err)
+			}
+			add(map[string]string{"numprocs": "NumProcs", "startsecs": "StartTime",
+				"startretries": "StartRetries", "stopwaitsecs": "StopTime"}[key.name], n)
+		case "autostart":
+			b, err := parseSupervisordBool(value)
+			if err != nil {
+				return nil, nil, invalid(err)
+			}
+			add("AutoStart", b)
+		case "autorestart":
+			if strings.ToLower(value) == "unexpected" {
+				add("AutoRestart", Unexpected)
+			} else if b, err := parseSupervisordBool(value); err != nil {
+				return nil, nil, invalid(err)
+			} else if b {
+				add("AutoRestart", Always)
+			} else {
+				add("AutoRestart", Never)
+			}
+		case "exitcodes":
+			codes := []int{}
+			for _, field := range strings.Split(value, ",") {
+				code, err := strconv.Atoi(strings.TrimSpace(field))
+				if err != nil {
+					return nil, nil, invalid(err)
+				}
+				codes = append(codes, code)
+			}
+			add("ExitCodes", codes)
+		case "stopsignal":
+			sig, exists := supervisordSignals[strings.TrimPrefix(strings.ToUpper(value), "SIG")]
+			if !exists {
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, nil, invalid(fmt.Errorf("unknown signal %s", value))
+				}
+				sig = syscall.Signal(n)
+			}
+			add("StopSignal", sig)
+		case "stdout_logfile", "stderr_logfile":
+			option := map[string]string{"stdout_logfile": "Outfile", "stderr_logfile": "Errfile"}[key.name]
+			switch strings.ToUpper(value) {
+			case "NONE":
+			case "AUTO":
+				warnings = append(warnings, fmt.Sprintf("line %d: %s AUTO of program %s is not supported, the output is discarded", key.line, key.name, name))
+			default:
+				add(option, value)
+			}
+		case "environment":
+			env, err := parseSupervisordEnv(value)
+			if err != nil {
+				return nil, nil, invalid(err)
+			}
+			//supervisord adds the environment to its own
+			add("Env", env)
+			add("InheritEnv", true)
+		case "directory":
+			add("WorkingDir", value)
+		case "umask":
+			umask, err := strconv.ParseUint(value, 8, 32)
+			if err != nil {
+				return nil, nil, invalid(err)
+			}
+			add("Umask", umask)
+		case "user":
+			add("User", value)
+		default:
+			warnings = append(warnings, fmt.Sprintf("line %d: %s of program %s is not supported, ignored", key.line, key.name, name))
+		}
+	}
+	return options, warnings, nil
+}
+
+//expandSupervisord replaces the %(name)s expressions known to us, and
+//returns the names of the other ones
+func expandSupervisord(value string, vars map[string]string) (string, []string) {
+	var unknown []string
+	value = supervisordExpansion.ReplaceAllStringFunc(value, func(expr string) string {
+		name := supervisordExpansion.FindStringSubmatch(expr)[1]
+		if v, exists := vars[name]; exists {
+			return v
+		}
+		if strings.HasPrefix(name, "ENV_") {
+			return os.Getenv(strings.TrimPrefix(name, "ENV_"))
+		}
+		unknown = append(unknown, name)
+		return expr
+	})
+	return strings.Replace(value, "%%", "%", -1), unknown
+}
+
+func parseSupervisordBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s is not a boolean", value)
+}
+
+//parseSupervisordEnv parses KEY="value",KEY2=value2 into KEY=value strings
+func parseSupervisordEnv(value string) ([]string, error) {
+	env := []string{}
+	i := 0
+	for {
+		for i < len(value) && strings.ContainsRune(", \t\n", rune(value[i])) {
+			i++
+		}
+		if i == len(value) {
+			return env, nil
+		}
+		eq := strings.IndexByte(value[i:], '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("expected KEY=value at %q", value[i:])
+		}
+		key := strings.TrimSpace(value[i : i+eq])
+		i += eq + 1
+		var v string
+		if i < len(value) && (value[i] == '"' || value[i] == '\'') {
+			end := strings.IndexByte(value[i+1:], value[i])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in the value of %s", key)
+			}
+			v = value[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			end := strings.IndexByte(value[i:], ',')
+			if end < 0 {
+				end = len(value) - i
+			}
+			v = strings.TrimSpace(value[i : i+end])
+			i += end
+		}
+		env = append(env, key+"="+v)
+	}
+}
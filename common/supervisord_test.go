@@ -0,0 +1,123 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const supervisordConfig = `; imported from supervisord
+[supervisord]
+logfile=/var/log/supervisord.log
+
+[unix_http_server]
+file=/tmp/supervisor.sock
+
+[program:web]
+command=/usr/bin/python3 -m http.server 8000
+numprocs=2
+autostart=false
+autorestart=true
+startsecs=5
+startretries=4
+exitcodes=0,3
+stopsignal=QUIT
+stopwaitsecs=20
+stdout_logfile=%(here)s/%(program_name)s.log ; inline comment
+environment=A="1,2",B='x y',
+    C=3
+directory=/srv
+umask=027
+priority=10
+
+[program:worker]
+command=/bin/sh -c "echo 'hi' && sleep 100"
+
+[program:errors]
+command=tail -F /var/log/app.log | grep error
+`
+
+func decodeSupervisord(t *testing.T, content string) ([]Process, []string) {
+	converted, _, warnings, err := SupervisordToJSON([]byte(content), "/etc/supervisor")
+	assert.Nil(t, err)
+	var config struct{ ProgList []Process }
+	assert.Nil(t, json.Unmarshal(converted, &config))
+	return config.ProgList, warnings
+}
+
+func TestSupervisordToJSON(t *testing.T) {
+	procs, warnings := decodeSupervisord(t, supervisordConfig)
+	assert.Equal(t, 3, len(procs))
+	web := procs[0]
+	assert.Equal(t, "web", web.Name)
+	assert.Equal(t, "/usr/bin/python3 -m http.server 8000", web.Command)
+	assert.False(t, web.Shell)
+	assert.Equal(t, uint(2), web.NumProcs)
+	assert.False(t, web.AutoStart)
+	assert.Equal(t, Always, web.AutoRestart)
+	assert.Equal(t, uint(5), web.StartTime)
+	assert.Equal(t, uint(4), web.StartRetries)
+	assert.Equal(t, []int{0, 3}, web.ExitCodes)
+	assert.Equal(t, syscall.SIGQUIT, web.StopSignal)
+	assert.Equal(t, uint(20), web.StopTime)
+	assert.Equal(t, "/etc/supervisor/web.log", web.Outfile)
+	assert.Equal(t, []string{"A=1,2", "B=x y", "C=3"}, web.Env)
+	assert.True(t, web.InheritEnv)
+	assert.Equal(t, "/srv", web.WorkingDir)
+	assert.Equal(t, uint32(027), web.Umask)
+
+	//the defaults of supervisord are kept
+	worker := procs[1]
+	//taskmaster does not split quoted arguments itself
+	assert.True(t, worker.Shell)
+	assert.True(t, worker.AutoStart)
+	assert.Equal(t, Unexpected, worker.AutoRestart)
+	assert.Equal(t, uint(1), worker.StartTime)
+	assert.Equal(t, []int{0}, worker.ExitCodes)
+	assert.Equal(t, syscall.SIGTERM, worker.StopSignal)
+	assert.True(t, procs[2].Shell)
+
+	assert.Equal(t, []string{
+		"line 23: priority of program web is not supported, ignored",
+	}, warnings)
+}
+
+func TestSupervisordProcessNum(t *testing.T) {
+	procs, warnings := decodeSupervisord(t, "[program:app]\ncommand=app --port 80%(process_num)s\nnumprocs=2\npriority=1\n")
+	assert.Equal(t, 2, len(procs))
+	for i, proc := range procs {
+		assert.Equal(t, fmt.Sprintf("app%d", i), proc.Name)
+		assert.Equal(t, fmt.Sprintf("app --port 80%d", i), proc.Command)
+		assert.Equal(t, uint(1), proc.NumProcs)
+	}
+	assert.Equal(t, []string{"line 4: priority of program app is not supported, ignored"}, warnings)
+
+	procs, _ = decodeSupervisord(t, "[program:app]\ncommand=app %(process_num)s\n")
+	assert.Equal(t, "app", procs[0].Name)
+	assert.Equal(t, "app 0", procs[0].Command)
+}
+
+func TestSupervisordErrors(t *testing.T) {
+	_, _, _, err := SupervisordToJSON([]byte("[program:a]\nstartsecs=soon\n"), "")
+	assert.Contains(t, err.Error(), "line 2, column 11: invalid startsecs of program a")
+	_, _, _, err = SupervisordToJSON([]byte("command=ls\n"), "")
+	assert.EqualError(t, err, "line 1: key outside of a section")
+	_, _, _, err = SupervisordToJSON([]byte("[program:a]\nenvironment=A=\"1\n"), "")
+	assert.NotNil(t, err)
+	_, _, _, err = SupervisordToJSON([]byte("[program:a]\ncommand=echo \"hi\n"), "")
+	assert.Contains(t, err.Error(), "invalid command of program a: unterminated quote")
+}
+
+func TestEnviron(t *testing.T) {
+	p := NewProc()
+	assert.NotEmpty(t, p.environ())
+	p.Env = []string{"A=1"}
+	assert.Equal(t, []string{"A=1"}, p.environ())
+	p.InheritEnv = true
+	env := p.environ()
+	assert.True(t, len(env) > 1)
+	assert.Equal(t, "A=1", env[len(env)-1])
+}
@@ -13,6 +13,7 @@ var (
 	g_warning *log.Logger
 	g_alert   *log.Logger
 	g_err     *log.Logger
+	g_audit   *log.Logger
 	g_rlog    *Rotlog
 	g_rotlock *sync.Mutex
 	g_silent  bool
@@ -23,6 +24,7 @@ func Init() {
 	g_warning = log.New(os.Stdout, "WARNING ", log.Ldate|log.Ltime)
 	g_alert = log.New(os.Stdout, "ALERT   ", log.Ldate|log.Ltime)
 	g_err = log.New(os.Stderr, "ERROR   ", log.Ldate|log.Ltime)
+	g_audit = log.New(os.Stdout, "AUDIT   ", log.Ldate|log.Ltime)
 	g_rlog = nil
 	g_silent = false
 	g_rotlock = &sync.Mutex{}
@@ -34,6 +36,7 @@ func InitSilent() {
 	g_warning = log.New(ioutil.Discard, "WARNING ", log.Ldate|log.Ltime)
 	g_alert = log.New(ioutil.Discard, "ALERT   ", log.Ldate|log.Ltime)
 	g_err = log.New(ioutil.Discard, "ERROR   ", log.Ldate|log.Ltime)
+	g_audit = log.New(ioutil.Discard, "AUDIT   ", log.Ldate|log.Ltime)
 	g_silent = true
 	g_rotlock = &sync.Mutex{}
 }
@@ -52,7 +55,7 @@ func writeAndSwap(log *log.Logger, msg string, stderr bool) {
 	g_rotlock.Lock()
 	defer g_rotlock.Unlock()
 	log.SetOutput(g_rlog)
-	log.Printf(msg)
+	log.Print(msg)
 	if g_silent {
 		log.SetOutput(ioutil.Discard)
 	} else if stderr {
@@ -93,3 +96,12 @@ func Error(s string, values ...interface{}) {
 		writeAndSwap(g_err, msg, true)
 	}
 }
+
+//Audit records an action taken on behalf of a client
+func Audit(s string, values ...interface{}) {
+	msg := fmt.Sprintf(s, values...)
+	g_audit.Print(msg)
+	if g_rlog != nil {
+		writeAndSwap(g_audit, msg, false)
+	}
+}
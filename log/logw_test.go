@@ -2,6 +2,8 @@ package logw
 
 import (
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
 	"testing"
 )
 
@@ -15,11 +17,13 @@ func TestInitLogw(t *testing.T) {
 	assert.NotNil(t, g_warning)
 	assert.NotNil(t, g_alert)
 	assert.NotNil(t, g_err)
+	assert.NotNil(t, g_audit)
 	//reset them to null for next test
 	g_info = nil
 	g_warning = nil
 	g_alert = nil
 	g_err = nil
+	g_audit = nil
 }
 
 func TestInitSilentLogw(t *testing.T) {
@@ -32,4 +36,19 @@ func TestInitSilentLogw(t *testing.T) {
 	assert.NotNil(t, g_warning)
 	assert.NotNil(t, g_alert)
 	assert.NotNil(t, g_err)
+	assert.NotNil(t, g_audit)
+}
+
+func TestAuditVerbs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testlogw")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	InitSilent()
+	assert.Nil(t, InitRotatingLog(dir+"/log", 1000, 2))
+	Audit("exec %s", "printf 100%d")
+	content, err := ioutil.ReadFile(dir + "/log")
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "exec printf 100%d\n")
+	g_rlog.current.Close()
+	g_rlog = nil
 }
@@ -27,13 +27,17 @@ type MethodFunc func(*rpc.Client, string) error
 
 var (
 	methodMap = map[string]MethodFunc{
-		"start":    StartProc,
-		"stop":     StopProc,
-		"shutdown": ShutDownServ,
-		"restart":  RestartProc,
-		"reload":   ReloadConfig,
+		"start":       StartProc,
+		"stop":        StopProc,
+		"shutdown":    ShutDownServ,
+		"restart":     RestartProc,
+		"reload":      ReloadConfig,
+		"upgrade":     UpgradeServer,
+		"release":     ReleaseProc,
+		"reload-proc": ReloadProc,
 	}
 	procList []string
+	exitCode int
 )
 
 func getProcList() []string {
@@ -87,7 +91,7 @@ func getSuffixFrom(word string, list []string) []string {
 }
 
 func autoComplete(line string) (c []string) {
-	comp := []string{"status", "reload", "start", "quit", "stop", "restart", "shutdown", "log"}
+	comp := []string{"status", "reload", "start", "quit", "stop", "restart", "shutdown", "log", "upgrade", "release", "crashes", "reload-proc", "info", "run", "exec"}
 	if len(line) == 0 {
 		return comp
 	}
@@ -112,15 +116,39 @@ func autoComplete(line string) (c []string) {
 	return
 }
 
-func main() {
-	port := flag.String("p", "4242", "Port for server connection")
-	flag.Parse()
-	client, err := rpc.DialHTTP("tcp", "127.0.0.1:"+*port)
+//connectionLost tells if the server went away, e.g. after an upgrade
+func connectionLost(err error) bool {
+	return err == rpc.ErrShutdown || err == io.ErrUnexpectedEOF || err == io.EOF
+}
+
+//readOnlyCommands may be sent again after the connection was lost, as they
+//change nothing even if the server already ran them
+var readOnlyCommands = map[string]bool{"status": true, "info": true, "log": true, "crashes": true}
+
+func connect(port string) *rpc.Client {
+	client, err := rpc.DialHTTP("tcp", "127.0.0.1:"+port)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to connect to the server\n%s\n", err)
 		os.Exit(1)
 	}
 	checkAuth(client)
+	return client
+}
+
+func main() {
+	port := flag.String("p", "4242", "Port for server connection")
+	flag.Parse()
+	client := connect(*port)
+	if flag.NArg() > 0 {
+		//a command given as arguments is run without prompting
+		args := flag.Args()
+		if err := CallMethod(client, args[0], args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		client.Close()
+		os.Exit(exitCode)
+	}
 	CallMethod(client, "status", []string{""})
 	line := liner.NewLiner()
 	line.SetCtrlCAborts(false)
@@ -147,6 +175,15 @@ func main() {
 				break
 			}
 			err = CallMethod(client, params[0], params[1:])
+			if connectionLost(err) {
+				client.Close()
+				client = connect(*port)
+				if readOnlyCommands[params[0]] {
+					err = CallMethod(client, params[0], params[1:])
+				} else {
+					err = fmt.Errorf("Connection to the server lost, %s may have run, check with status", params[0])
+				}
+			}
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 			}
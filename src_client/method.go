@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/rpc"
+	"os"
 	"strconv"
 	"strings"
 	"taskmaster/common"
@@ -20,7 +21,7 @@ func GetStatus(client *rpc.Client, args []string) error {
 		ret = ret[:len(ret)-1]
 	}
 	for _, p := range ret {
-		fmt.Printf(p.String())
+		fmt.Print(p.String())
 	}
 	return nil
 }
@@ -48,11 +49,101 @@ func GetLog(client *rpc.Client, params []string) error {
 	return nil
 }
 
+func GetInfo(client *rpc.Client, procName string) error {
+	var info common.ProcInfo
+	err := client.Call("Handler.GetInfo", procName, &info)
+	if err != nil {
+		return err
+	}
+	fmt.Print(info.String())
+	return nil
+}
+
+func GetCrashes(client *rpc.Client, procName string) error {
+	var ret []string
+	err := client.Call("Handler.GetCrashes", procName, &ret)
+	if err != nil {
+		return err
+	}
+	if len(ret) == 0 {
+		fmt.Printf("No crash recorded for %s\n", procName)
+	}
+	for _, crash := range ret {
+		fmt.Println(crash)
+	}
+	return nil
+}
+
+//RunTask runs a oneshot task and waits for its completion. Its exit code
+//becomes the exit code of the client when it is not interactive
+func RunTask(client *rpc.Client, taskName string) error {
+	var ret common.TaskResult
+	err := client.Call("Handler.RunTask", taskName, &ret)
+	if err != nil {
+		return err
+	}
+	for !ret.Done {
+		req := common.TaskRequest{Name: taskName, Since: ret.Since}
+		if err := client.Call("Handler.WaitTask", req, &ret); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Task %s %s with exit code %d\n", taskName, strings.ToLower(ret.State), ret.ExitCode)
+	exitCode = ret.ExitCode
+	return nil
+}
+
+//Exec runs a command with the settings of a process and streams its output.
+//Its exit code becomes the exit code of the client when it is not interactive
+func Exec(client *rpc.Client, procName string, args []string) error {
+	var id int
+	err := client.Call("Handler.ExecStart", common.ExecRequest{Name: procName, Args: args}, &id)
+	if err != nil {
+		return err
+	}
+	for {
+		var out common.ExecOutput
+		if err := client.Call("Handler.ExecRead", id, &out); err != nil {
+			return err
+		}
+		os.Stdout.Write(out.Stdout)
+		os.Stderr.Write(out.Stderr)
+		if out.Done {
+			exitCode = out.ExitCode
+			return nil
+		}
+	}
+}
+
 func CallMethod(client *rpc.Client, command string, args []string) error {
 	var argList []string
 	if command == "log" {
 		return GetLog(client, args)
 	}
+	if command == "info" {
+		if len(args) != 1 {
+			return fmt.Errorf("Usage: info <process>")
+		}
+		return GetInfo(client, args[0])
+	}
+	if command == "exec" {
+		if len(args) < 3 || args[1] != "--" {
+			return fmt.Errorf("Usage: exec <process> -- <command...>")
+		}
+		return Exec(client, args[0], args[2:])
+	}
+	if command == "run" {
+		if len(args) != 1 {
+			return fmt.Errorf("Usage: run <task>")
+		}
+		return RunTask(client, args[0])
+	}
+	if command == "crashes" {
+		if len(args) != 1 {
+			return fmt.Errorf("Usage: crashes <process>")
+		}
+		return GetCrashes(client, args[0])
+	}
 	if command == "status" {
 		if len(args) == 0 || args[0] == "all" {
 			return GetStatus(client, []string{""})
@@ -64,7 +155,7 @@ func CallMethod(client *rpc.Client, command string, args []string) error {
 	} else {
 		argList = args
 	}
-	if command == "shutdown" || command == "reload" {
+	if command == "shutdown" || command == "reload" || command == "upgrade" {
 		argList = []string{""}
 	}
 	f, exists := methodMap[command]
@@ -89,7 +180,11 @@ func StartProc(client *rpc.Client, procName string) error {
 		return err
 	}
 	for _, status := range ret {
-		fmt.Printf("Started %s with pid %d\n", status.Name, status.Pid)
+		if status.State == common.Waiting {
+			fmt.Printf("%s is waiting: %s\n", status.Name, status.Message)
+		} else {
+			fmt.Printf("Started %s with pid %d\n", status.Name, status.Pid)
+		}
 	}
 	return nil
 }
@@ -120,6 +215,32 @@ func RestartProc(client *rpc.Client, procName string) error {
 	return nil
 }
 
+func ReloadProc(client *rpc.Client, procName string) error {
+	var ret []common.ProcStatus
+	method := common.ServerMethod{MethodName: "ReloadProc", Param: procName}
+	err := client.Call("Handler.AddMethod", method, &ret)
+	if err != nil {
+		return err
+	}
+	for _, status := range ret {
+		fmt.Printf("Reloaded %s\n", status.Name)
+	}
+	return nil
+}
+
+func ReleaseProc(client *rpc.Client, procName string) error {
+	var ret []common.ProcStatus
+	method := common.ServerMethod{MethodName: "ReleaseProc", Param: procName}
+	err := client.Call("Handler.AddMethod", method, &ret)
+	if err != nil {
+		return err
+	}
+	for _, status := range ret {
+		fmt.Printf("Released %s with pid %d\n", status.Name, status.Pid)
+	}
+	return nil
+}
+
 func ReloadConfig(client *rpc.Client, procName string) error {
 	var ret []common.ProcStatus
 	err := client.Call("Handler.ReloadConfig", "", &ret)
@@ -130,6 +251,19 @@ func ReloadConfig(client *rpc.Client, procName string) error {
 	return nil
 }
 
+func UpgradeServer(client *rpc.Client, param string) error {
+	var ret []common.ProcStatus
+	method := common.ServerMethod{MethodName: "Upgrade", Param: param}
+	err := client.Call("Handler.AddMethod", method, &ret)
+	if err != nil {
+		return err
+	}
+	if len(ret) == 1 {
+		fmt.Println(ret[0].State)
+	}
+	return nil
+}
+
 func lol(stop chan bool) {
 	for {
 		select {
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"taskmaster/common"
+	"taskmaster/log"
+	"time"
+)
+
+const (
+	//how long a read waits for output before returning an empty one
+	execPoll = time.Second
+	//how long the output of a finished command is kept for its client
+	execKeep = time.Minute
+	//how long a command runs without its output being read before it is
+	//killed, its client being gone
+	execIdle = 10 * time.Second
+)
+
+var (
+	execs    = make(map[int]*common.Exec)
+	execLock = new(sync.Mutex)
+	execID   int
+)
+
+//ExecStart runs a transient command with the settings of a process and
+//returns the id used to read its output. The command is not a program of
+//the server and is never restarted
+func (h *Handler) ExecStart(req common.ExecRequest, res *int) error {
+	if !h.isUserAuth() {
+		return errors.New("You are not authenticated. Restart your client")
+	}
+	proc, exists := getProc(req.Name)
+	if !exists {
+		return errors.New(fmt.Sprintf("Process not found: %s", req.Name))
+	}
+	execLock.Lock()
+	execID++
+	id := execID
+	execLock.Unlock()
+	e, err := proc.NewExec(req.Args)
+	if err == nil {
+		err = e.Start()
+	}
+	if err != nil {
+		logw.Audit("Exec %d in the environment of %s failed to start: %s: %s", id, req.Name, strings.Join(req.Args, " "), err)
+		return err
+	}
+	logw.Audit("Exec %d in the environment of %s started with pid %d: %s", id, req.Name, e.Cmd.Process.Pid, strings.Join(req.Args, " "))
+	execLock.Lock()
+	execs[id] = e
+	execLock.Unlock()
+	go func() {
+		for ended := false; !ended; {
+			select {
+			case <-e.Ended():
+				ended = true
+			case <-time.After(execPoll):
+				if time.Since(e.LastRead()) > execIdle {
+					logw.Audit("Exec %d in the environment of %s is killed, its output is not read anymore", id, req.Name)
+					e.Cmd.Process.Kill()
+					<-e.Ended()
+					ended = true
+				}
+			}
+		}
+		logw.Audit("Exec %d in the environment of %s exited with code %d", id, req.Name, e.ExitCode())
+		//the output of a command whose client went away is dropped
+		time.Sleep(execKeep)
+		forgetExec(id)
+	}()
+	*res = id
+	return nil
+}
+
+//ExecRead returns the output of a transient command since the last read.
+//The command is forgotten once it has ended and its output has been read
+func (h *Handler) ExecRead(id int, res *common.ExecOutput) error {
+	if !h.isUserAuth() {
+		return errors.New("You are not authenticated. Restart your client")
+	}
+	execLock.Lock()
+	e, exists := execs[id]
+	execLock.Unlock()
+	if !exists {
+		return errors.New(fmt.Sprintf("No such exec: %d", id))
+	}
+	out := e.Read(execPoll)
+	if out.Done {
+		forgetExec(id)
+	}
+	*res = out
+	return nil
+}
+
+func forgetExec(id int) {
+	execLock.Lock()
+	defer execLock.Unlock()
+	delete(execs, id)
+}
+
+//killExecs kills the transient commands, whose output cannot be read anymore
+//once the server is upgraded
+func killExecs() {
+	execLock.Lock()
+	running := make([]*common.Exec, 0, len(execs))
+	for _, e := range execs {
+		running = append(running, e)
+	}
+	execLock.Unlock()
+	for _, e := range running {
+		e.Cmd.Process.Kill()
+		select {
+		case <-e.Ended():
+		case <-time.After(time.Second):
+		}
+	}
+}
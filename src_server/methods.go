@@ -17,14 +17,42 @@ func (h *Handler) handleProcess(proc *common.Process, state chan error) {
 	started := make(chan bool)
 	for tries <= proc.GetStartRetries() || proc.GetAutoRestart() == common.Always {
 		tries++
+		proc.SetTries(tries)
+		if tries > 1 && proc.IsCrashLooping() {
+			select {
+			case resp := <-proc.Die:
+				//Backoff reload
+				proc.SetStatus(common.Stopped)
+				resp <- false
+				return
+			default:
+			}
+			close(state)
+			proc.CloseLogs()
+			proc.SetStatus(common.Quarantined)
+			logw.Alert("Process %s restarted more than %d times in %ds, quarantined until released",
+				proc.Name, proc.GetRestartLimit(), proc.GetRestartWindow())
+			return
+		}
 		proc.SetKilled(false)
+		if !waitConditions(proc, state) {
+			return
+		}
+		startTime := proc.GetStartTime()
+		if proc.GetAdopted() != 0 {
+			//an adopted process has already been running long enough
+			startTime = 0
+		}
+		ok := h.startAttempt(proc, state, started, processEnd)
 		timeout := make(chan bool, 1)
-		go func() {
-			time.Sleep(time.Second * time.Duration(proc.GetStartTime()))
-			timeout <- true
-		}()
-		go proc.Start(started, processEnd)
-		ok := <-started
+		if n := proc.GetNotifier(); n != nil {
+			go waitReady(proc, n, startTime, timeout)
+		} else {
+			go func() {
+				time.Sleep(time.Second * time.Duration(startTime))
+				timeout <- true
+			}()
+		}
 		//process has started normally
 		if ok {
 			proc.SetStatus(common.Starting)
@@ -35,6 +63,7 @@ func (h *Handler) handleProcess(proc *common.Process, state chan error) {
 				//process has run enough time
 				proc.SetStatus(common.Running)
 				logw.Info("%s started successfully with pid %d", proc.Name, proc.GetPid())
+				go proc.RunHook(common.HookPostStart, proc.GetPid(), 0)
 				select {
 				case <-processEnd:
 				case resp := <-proc.Die:
@@ -42,14 +71,15 @@ func (h *Handler) handleProcess(proc *common.Process, state chan error) {
 					resp <- true
 					<-processEnd
 				}
-				if proc.GetKilled() {
+				postStop(proc)
+				if proc.GetKilled() && proc.GetStopReason() != common.ReasonTimeout {
 					//process killed by stop command
 					proc.SetStatus(common.Stopped)
 					logw.Info("Stopped %s", proc.Name)
 					close(state)
 					return
 				} else {
-					//process exited normally
+					//process exited normally, or was stopped after its maximum runtime
 					proc.SetStatus(common.Exited)
 					if proc.GetAutoRestart() == common.Unexpected && proc.HasCorrectlyExit() {
 						close(state)
@@ -57,7 +87,8 @@ func (h *Handler) handleProcess(proc *common.Process, state chan error) {
 					}
 				}
 			case <-processEnd:
-				if proc.GetKilled() {
+				postStop(proc)
+				if proc.GetKilled() && proc.GetStopReason() != common.ReasonTimeout {
 					//process killed by stop command
 					proc.SetStatus(common.Stopped)
 					logw.Info("Stopped %s", proc.Name)
@@ -80,6 +111,13 @@ func (h *Handler) handleProcess(proc *common.Process, state chan error) {
 				break
 			}
 		} else {
+			if proc.GetKilled() {
+				//stopped while its pre-start hook ran
+				proc.SetStatus(common.Stopped)
+				logw.Info("Stopped %s", proc.Name)
+				close(state)
+				return
+			}
 			select {
 			case resp := <-proc.Die:
 				//Backoff reload
@@ -106,12 +144,229 @@ func (h *Handler) handleProcess(proc *common.Process, state chan error) {
 	proc.SetStatus(common.Fatal)
 }
 
-func (h *Handler) StartProc(param string, res *[]common.ProcStatus) error {
-	var statuses []common.ProcStatus
+//handleOneshot runs a oneshot task until it exits with one of its ExitCodes,
+//trying it at most StartRetries more times. Unlike other programs, a task is
+//expected to exit, so it does not have to run for StartTime seconds
+func (h *Handler) handleOneshot(proc *common.Process, state chan error) {
+	processEnd := make(chan bool)
+	started := make(chan bool)
+	for tries := uint(1); tries <= proc.GetStartRetries()+1; tries++ {
+		proc.SetTries(tries)
+		proc.SetKilled(false)
+		if !waitConditions(proc, state) {
+			return
+		}
+		if h.startAttempt(proc, state, started, processEnd) {
+			proc.SetStatus(common.Running)
+			logw.Info("Task %s started with pid %d", proc.Name, proc.GetPid())
+			state <- nil
+			go proc.RunHook(common.HookPostStart, proc.GetPid(), 0)
+			select {
+			case <-processEnd:
+			case resp := <-proc.Die:
+				//Task will be killed normally (going from backoff)
+				resp <- true
+				<-processEnd
+			}
+			postStop(proc)
+			if proc.GetKilled() && proc.GetStopReason() != common.ReasonTimeout {
+				//task killed by stop command
+				proc.SetStatus(common.Stopped)
+				logw.Info("Stopped %s", proc.Name)
+				close(state)
+				return
+			}
+			if proc.HasCorrectlyExit() {
+				close(state)
+				proc.CloseLogs()
+				proc.SetStatus(common.Succeeded)
+				logw.Info("Task %s succeeded", proc.Name)
+				return
+			}
+			proc.SetStatus(common.Backoff)
+			logw.Warning("Task %s failed with exit code %d", proc.Name, proc.GetExitCode())
+		} else {
+			if proc.GetKilled() {
+				//stopped while its pre-start hook ran
+				proc.SetStatus(common.Stopped)
+				logw.Info("Stopped %s", proc.Name)
+				close(state)
+				return
+			}
+			select {
+			case resp := <-proc.Die:
+				//Backoff reload
+				proc.SetStatus(common.Stopped)
+				resp <- false
+				return
+			default:
+			}
+			proc.SetStatus(common.Backoff)
+			state <- errors.New(fmt.Sprintf("Unable to start task %s", proc.Name))
+			logw.Warning("Unable to start task %s", proc.Name)
+		}
+	}
+	select {
+	case resp := <-proc.Die:
+		//Backoff reload
+		proc.SetStatus(common.Stopped)
+		resp <- false
+		return
+	default:
+	}
+	close(state)
+	proc.CloseLogs()
+	proc.SetStatus(common.Failed)
+	logw.Error("Task %s failed after %d tries", proc.Name, proc.GetStartRetries()+1)
+}
 
+//requiresInterval is how often a process waiting for the programs it requires
+//checks them again
+const requiresInterval = time.Second
+
+//waitConditions keeps a process WAITING until the programs it requires are
+//ready and its start conditions are met, without using its start retries. It
+//returns false when the process was stopped while waiting
+func waitConditions(proc *common.Process, state chan error) bool {
+	reason, required := startBlocker(proc)
+	if reason == "" {
+		return true
+	}
+	proc.SetMessage(reason)
+	proc.SetStatus(common.Waiting)
+	logw.Info("Process %s is waiting: %s", proc.Name, reason)
+	state <- nil
+	stopped := func(resp chan bool) bool {
+		proc.SetMessage("")
+		proc.SetNextRetry(time.Time{})
+		proc.SetStatus(common.Stopped)
+		resp <- false
+		return false
+	}
+	for reason != "" {
+		interval := time.Duration(proc.GetConditions().Interval) * time.Second
+		if required {
+			interval = requiresInterval
+		}
+		proc.SetNextRetry(time.Now().Add(interval))
+		select {
+		case resp := <-proc.Die:
+			return stopped(resp)
+		case <-time.After(interval):
+		}
+		//checking the required programs takes the global lock, which is held
+		//by whoever stops this process
+		type blocker struct {
+			reason   string
+			required bool
+		}
+		result := make(chan blocker, 1)
+		go func() {
+			next, required := startBlocker(proc)
+			result <- blocker{next, required}
+		}()
+		var next blocker
+		select {
+		case resp := <-proc.Die:
+			return stopped(resp)
+		case next = <-result:
+		}
+		if next.reason != reason && next.reason != "" {
+			proc.SetMessage(next.reason)
+			logw.Info("Process %s is waiting: %s", proc.Name, next.reason)
+		}
+		reason, required = next.reason, next.required
+	}
+	proc.SetMessage("")
+	proc.SetNextRetry(time.Time{})
+	logw.Info("Start conditions of process %s are met", proc.Name)
+	return true
+}
+
+//startBlocker tells why a process cannot be started yet, and if it is
+//because of a program it requires
+func startBlocker(proc *common.Process) (string, bool) {
+	for _, name := range proc.GetRequires() {
+		required, exists := getProc(name)
+		if !exists {
+			return fmt.Sprintf("requires %s, which does not exist", name), true
+		}
+		if !required.RequirementMet() {
+			return fmt.Sprintf("requires %s, which is %s", name, required.GetProcStatus().State), true
+		}
+	}
+	return proc.CheckConditions(), false
+}
+
+//failedRequirement returns a program required by proc which gave up, if any
+func failedRequirement(proc *common.Process) *common.Process {
+	for _, name := range proc.GetRequires() {
+		if required, exists := getProc(name); exists && required.RequirementFailed() {
+			return required
+		}
+	}
+	return nil
+}
+
+//startAttempt runs the pre-start hook then starts the process. A failing
+//hook makes the attempt fail unless the process is configured to ignore it.
+//The process is reported as starting before its hook runs, which may take up
+//to the hook timeout, and the attempt fails if it is stopped meanwhile
+func (h *Handler) startAttempt(proc *common.Process, state chan error, started, processEnd chan bool) bool {
+	if proc.GetAdopted() == 0 && proc.GetHooks().PreStart != "" {
+		proc.SetMessage("running its pre-start hook")
+		proc.SetStatus(common.Starting)
+		state <- nil
+		err := proc.RunHook(common.HookPreStart, 0, 0)
+		proc.SetMessage("")
+		if proc.GetKilled() {
+			return false
+		}
+		if err != nil && proc.GetHooks().OnPreStartFailure == common.HookAbort {
+			logw.Warning("Pre-start hook of process %s failed, aborting start", proc.Name)
+			proc.SetLastError(fmt.Sprintf("pre-start hook failed: %s", err))
+			return false
+		}
+	}
+	go proc.Start(started, processEnd)
+	return <-started
+}
+
+//waitReady waits for a process using the notify protocol to report READY=1.
+//A process which is not ready after startTime seconds is killed
+func waitReady(proc *common.Process, n *common.Notifier, startTime uint, timeout chan bool) {
+	select {
+	case <-n.Ready:
+		timeout <- true
+	case <-n.Done:
+	case <-time.After(time.Second * time.Duration(startTime)):
+		logw.Warning("Process %s did not report readiness after %ds", proc.Name, startTime)
+		if pid := proc.GetPid(); pid > 0 {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+}
+
+//postStop runs the post-stop hook of a process which has just ended
+func postStop(proc *common.Process) {
+	history := proc.GetHistory()
+	if len(history) == 0 {
+		return
+	}
+	last := history[len(history)-1]
+	go proc.RunHook(common.HookPostStop, last.Pid, last.ExitCode)
+}
+
+func (h *Handler) StartProc(param string, res *[]common.ProcStatus) error {
 	if !h.isUserAuth() {
 		return errors.New("You are not authenticated. Restart your client")
 	}
+	return h.startProc(param, res)
+}
+
+func (h *Handler) startProc(param string, res *[]common.ProcStatus) error {
+	var statuses []common.ProcStatus
+
 	proc, exists := g_procs[param]
 	if !exists {
 		logw.Warning("Process not found: %s", param)
@@ -122,8 +377,20 @@ func (h *Handler) StartProc(param string, res *[]common.ProcStatus) error {
 		status.State == common.Running {
 		return errors.New(fmt.Sprintf("Process already running: %s", param))
 	}
+	if status.State == common.Waiting || status.State == common.Backoff {
+		return errors.New(fmt.Sprintf("Process %s is already being started", param))
+	}
+	if status.State == common.Quarantined {
+		return errors.New(fmt.Sprintf("Process %s is quarantined, release it first", param))
+	}
+	proc.SetManualStop(false)
+	h.startRequired(proc)
 	state := make(chan error)
-	go h.handleProcess(proc, state)
+	if proc.IsOneshot() {
+		go h.handleOneshot(proc, state)
+	} else {
+		go h.handleProcess(proc, state)
+	}
 	err := <-state
 	go func() {
 		for {
@@ -141,17 +408,53 @@ func (h *Handler) StartProc(param string, res *[]common.ProcStatus) error {
 	return nil
 }
 
+//startRequired starts the programs required by a process which have not been
+//started yet. The others are waited for by the process itself
+func (h *Handler) startRequired(proc *common.Process) {
+	for _, name := range proc.GetRequires() {
+		required, exists := g_procs[name]
+		if !exists {
+			continue
+		}
+		//the requirements which gave up are given another chance
+		switch required.GetProcStatus().State {
+		case common.Stopped, common.Failed, common.Exited, common.Fatal:
+		default:
+			continue
+		}
+		logw.Info("Starting %s, required by %s", name, proc.Name)
+		var useless []common.ProcStatus
+		if err := h.startProc(name, &useless); err != nil {
+			logw.Warning("Unable to start %s, required by %s: %s", name, proc.Name, err)
+		}
+	}
+}
+
 func (h *Handler) StopProc(param string, res *[]common.ProcStatus) error {
 	if !h.isUserAuth() {
 		return errors.New("You are not authenticated. Restart your client")
 	}
+	return h.stopManually(param, res)
+}
+
+//stopManually stops a process which is not to be lazily started again, like
+//one stopped by the operator
+func (h *Handler) stopManually(param string, res *[]common.ProcStatus) error {
+	err := h.stopProc(param, res)
+	if proc, exists := g_procs[param]; exists && err == nil {
+		proc.SetManualStop(true)
+	}
+	return err
+}
+
+func (h *Handler) stopProc(param string, res *[]common.ProcStatus) error {
 	proc, exists := g_procs[param]
 	if !exists {
 		logw.Warning("Process not found: %s", param)
 		return errors.New(fmt.Sprintf("Process not found: %s", param))
 	}
 	status := proc.GetProcStatus()
-	if status.State == common.Backoff {
+	if status.State == common.Backoff || status.State == common.Waiting {
 		response := make(chan bool)
 		proc.Die <- response
 		v := <-response
@@ -164,36 +467,71 @@ func (h *Handler) StopProc(param string, res *[]common.ProcStatus) error {
 	if statu != common.Starting && statu != common.Running {
 		return errors.New(fmt.Sprintf("Process %s is not running", proc.Name))
 	}
-	timeout := make(chan bool, 1)
-	stopped := make(chan bool, 1)
+	//a process stopped after its maximum runtime may be restarted right away
+	restarting := proc.GetStopReason() == common.ReasonTimeout
 	proc.SetKilled(true)
-	syscall.Kill(proc.Cmd.Process.Pid, proc.StopSignal)
-	go func() {
-		time.Sleep(time.Duration(proc.GetStopTime()) * time.Second)
-		timeout <- true
-	}()
-	go func() {
-		for {
-			if proc.GetProcStatus().State == common.Stopped {
-				stopped <- true
-				return
-			}
+	pid := proc.GetPid()
+	if !proc.TakePreStop(pid) {
+		proc.RunHook(common.HookPreStop, pid, 0)
+	}
+	for i, step := range proc.GetStopSequence() {
+		if i > 0 {
+			logw.Info("Process %s is still running, escalating to signal %d (%s)", proc.Name, step.Signal, step.Signal)
 		}
-	}()
-	select {
-	case <-timeout:
-		if proc.GetProcStatus().State != common.Stopped {
-			syscall.Kill(proc.Cmd.Process.Pid, syscall.SIGKILL)
-			logw.Info("Process %s was killed by SIGKILL dans sa face", proc.Name)
+		if pid > 0 {
+			syscall.Kill(pid, step.Signal)
+		}
+		if waitStopped(proc, pid, step.Wait, restarting) {
+			logw.Info("Process %s was stopped by signal %d (%s)", proc.Name, step.Signal, step.Signal)
+			break
 		}
-	case <-stopped:
-		logw.Info("Process %s was killed normally", proc.Name)
 	}
-	proc.CloseLogs()
+	if !restarting {
+		proc.CloseLogs()
+	}
 	*res = []common.ProcStatus{proc.GetProcStatus()}
 	return nil
 }
 
+//waitStopped waits at most wait seconds for a process to be stopped. A
+//process which may be restarted never reaches the Stopped state, so only its
+//run with pid has to end
+func waitStopped(proc *common.Process, pid int, wait uint, restarting bool) bool {
+	deadline := time.Now().Add(time.Duration(wait) * time.Second)
+	for {
+		if restarting {
+			history := proc.GetHistory()
+			if len(history) > 0 && history[len(history)-1].Pid == pid {
+				return true
+			}
+		} else if proc.GetProcStatus().State == common.Stopped {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+//preStop runs the pre-stop hook of a running process outside of the action
+//loop, since it may take long, before its stop is queued
+func (h *Handler) preStop(name string) {
+	proc, exists := getProc(name)
+	if !exists {
+		return
+	}
+	if state := proc.GetProcStatus().State; state == common.Starting || state == common.Running {
+		proc.RunPreStop()
+	}
+}
+
+//queueStop queues a method stopping a process, after its pre-stop hook
+func (h *Handler) queueStop(method MethodFunc, name string) error {
+	h.preStop(name)
+	return h.queue(method, name)
+}
+
 func (h *Handler) GetLog(nbLines int, res *[]string) error {
 	if !h.isUserAuth() {
 		return errors.New("You are not authenticated. Restart your client")
@@ -217,19 +555,136 @@ func (h *Handler) GetLog(nbLines int, res *[]string) error {
 	return nil
 }
 
+func (h *Handler) GetCrashes(name string, res *[]string) error {
+	if !h.isUserAuth() {
+		return errors.New("You are not authenticated. Restart your client")
+	}
+	proc, exists := getProc(name)
+	if !exists {
+		return errors.New(fmt.Sprintf("Process not found: %s", name))
+	}
+	reports, err := proc.ListCrashes()
+	if err != nil {
+		return err
+	}
+	lines := []string{}
+	for _, report := range reports {
+		lines = append(lines, report.String())
+	}
+	*res = lines
+	return nil
+}
+
+func (h *Handler) GetInfo(name string, res *common.ProcInfo) error {
+	if !h.isUserAuth() {
+		return errors.New("You are not authenticated. Restart your client")
+	}
+	proc, exists := getProc(name)
+	if !exists {
+		return errors.New(fmt.Sprintf("Process not found: %s", name))
+	}
+	info, err := proc.GetInfo()
+	if err != nil {
+		return err
+	}
+	*res = info
+	return nil
+}
+
+//taskPoll is how long WaitTask waits at most, so that it does not wait any
+//longer for a client which went away
+const taskPoll = 5 * time.Second
+
+//RunTask starts a oneshot task, unless it is already running. The client
+//then waits for its completion with WaitTask
+func (h *Handler) RunTask(name string, res *common.TaskResult) error {
+	if !h.isUserAuth() {
+		return errors.New("You are not authenticated. Restart your client")
+	}
+	proc, exists := getProc(name)
+	if !exists {
+		return errors.New(fmt.Sprintf("Process not found: %s", name))
+	}
+	if !proc.IsOneshot() {
+		return errors.New(fmt.Sprintf("Process %s is not a oneshot task", name))
+	}
+	//the run we wait for is the first one ending after the last recorded one
+	var previous time.Time
+	if history := proc.GetHistory(); len(history) > 0 {
+		previous = history[len(history)-1].End
+	}
+	switch proc.GetProcStatus().State {
+	case common.Starting, common.Running, common.Stopping, common.Backoff, common.Waiting:
+	default:
+		if err := h.queue(h.startProc, name); err != nil {
+			logw.Warning("Task %s: %s", name, err)
+		}
+	}
+	*res = common.TaskResult{State: proc.GetProcStatus().State, Since: previous}
+	return nil
+}
+
+//WaitTask waits at most taskPoll for the end of the run of a oneshot task
+//started by RunTask. The result is not Done when the run is still going on
+func (h *Handler) WaitTask(req common.TaskRequest, res *common.TaskResult) error {
+	if !h.isUserAuth() {
+		return errors.New("You are not authenticated. Restart your client")
+	}
+	proc, exists := getProc(req.Name)
+	if !exists {
+		return errors.New(fmt.Sprintf("Process not found: %s", req.Name))
+	}
+	deadline := time.Now().Add(taskPoll)
+	for {
+		state := proc.GetProcStatus().State
+		if common.IsDone(state) || state == common.Stopped {
+			break
+		}
+		if state == common.Waiting {
+			if required := failedRequirement(proc); required != nil {
+				return errors.New(fmt.Sprintf("Task %s failed: requires %s, which is %s",
+					req.Name, required.Name, required.GetProcStatus().State))
+			}
+		}
+		if time.Now().After(deadline) {
+			*res = common.TaskResult{State: state, Since: req.Since}
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	status := proc.GetProcStatus()
+	if status.State == common.Stopped {
+		return errors.New(fmt.Sprintf("Task %s was stopped", req.Name))
+	}
+	history := proc.GetHistory()
+	if len(history) == 0 || !history[len(history)-1].End.After(req.Since) {
+		//the task never started
+		return errors.New(fmt.Sprintf("Task %s failed: %s", req.Name, proc.GetLastError()))
+	}
+	*res = common.TaskResult{State: status.State, ExitCode: history[len(history)-1].ExitCode, Done: true}
+	return nil
+}
+
 func (h *Handler) ReloadConfig(param string, res *[]common.ProcStatus) error {
 	if !h.isUserAuth() {
 		return errors.New("You are not authenticated. Restart your client")
 	}
-	newConf, err := LoadFile(h.configFile)
+	return h.reloadConfig(param, res)
+}
+
+func (h *Handler) reloadConfig(param string, res *[]common.ProcStatus) error {
+	newConf, err := LoadFile(h.configFile, h.procfile)
 	if err != nil {
-		logw.Error("Unable to load config file: %s", h.configFile)
+		logw.Error("Unable to load config file %s, keeping the running config: %s", h.configFile, err)
 		return err
 	}
+	logw.Info("Reloading config: %s", configDiff(newConf))
 	h.Pause <- true
 	h.removeProcs(newConf)
-	h.updateWhatMustBeUpdated(newConf)
-	h.handleAutoStart()
+	restarted := h.updateWhatMustBeUpdated(newConf)
+	h.updateSockets()
+	h.updateWatchers()
+	h.handleAutoStart(restarted)
 	h.Continue <- true
 	*res = []common.ProcStatus{}
 	return nil
@@ -239,13 +694,73 @@ func (h *Handler) RestartProc(param string, res *[]common.ProcStatus) error {
 	if !h.isUserAuth() {
 		return errors.New("You are not authenticated. Restart your client")
 	}
-	err := h.StopProc(param, res)
+	return h.restartProc(param, res)
+}
+
+func (h *Handler) restartProc(param string, res *[]common.ProcStatus) error {
+	err := h.stopProc(param, res)
 	if err == nil {
-		err = h.StartProc(param, res)
+		err = h.startProc(param, res)
 	}
 	return err
 }
 
+func (h *Handler) ReloadProc(param string, res *[]common.ProcStatus) error {
+	if !h.isUserAuth() {
+		return errors.New("You are not authenticated. Restart your client")
+	}
+	return h.reloadProc(param, res)
+}
+
+//reloadProc asks a running process to reload itself in place
+func (h *Handler) reloadProc(param string, res *[]common.ProcStatus) error {
+	proc, exists := g_procs[param]
+	if !exists {
+		logw.Warning("Process not found: %s", param)
+		return errors.New(fmt.Sprintf("Process not found: %s", param))
+	}
+	if state := proc.GetProcStatus().State; state != common.Running && state != common.Starting {
+		return errors.New(fmt.Sprintf("Process %s is not running", param))
+	}
+	if err := proc.Reload(); err != nil {
+		logw.Warning("Unable to reload process %s: %s", param, err)
+		return err
+	}
+	logw.Info("Reloaded process %s", param)
+	*res = []common.ProcStatus{proc.GetProcStatus()}
+	return nil
+}
+
+func (h *Handler) ReleaseProc(param string, res *[]common.ProcStatus) error {
+	if !h.isUserAuth() {
+		return errors.New("You are not authenticated. Restart your client")
+	}
+	return h.releaseProc(param, res)
+}
+
+//releaseProc takes a process out of quarantine and starts it again
+func (h *Handler) releaseProc(param string, res *[]common.ProcStatus) error {
+	proc, exists := g_procs[param]
+	if !exists {
+		logw.Warning("Process not found: %s", param)
+		return errors.New(fmt.Sprintf("Process not found: %s", param))
+	}
+	if proc.GetProcStatus().State != common.Quarantined {
+		return errors.New(fmt.Sprintf("Process %s is not quarantined", param))
+	}
+	logw.Info("Releasing process %s from quarantine", param)
+	proc.ClearRestarts()
+	proc.SetStatus(common.Stopped)
+	return h.startProc(param, res)
+}
+
+func (h *Handler) Upgrade(param string, res *[]common.ProcStatus) error {
+	if !h.isUserAuth() {
+		return errors.New("You are not authenticated. Restart your client")
+	}
+	return h.upgrade(param, res)
+}
+
 func (h *Handler) Shutdown(param string, res *[]common.ProcStatus) error {
 	if !h.isUserAuth() {
 		return errors.New("You are not authenticated. Restart your client")
@@ -254,7 +769,7 @@ func (h *Handler) Shutdown(param string, res *[]common.ProcStatus) error {
 	lock.Lock()
 	for k, proc := range g_procs {
 		s := proc.GetProcStatus().State
-		if s == common.Running || s == common.Starting || s == common.Backoff {
+		if s == common.Running || s == common.Starting || s == common.Backoff || s == common.Waiting {
 			var u []common.ProcStatus
 			h.StopProc(k, &u)
 		}
@@ -262,13 +777,15 @@ func (h *Handler) Shutdown(param string, res *[]common.ProcStatus) error {
 	return nil
 }
 
-func (h *Handler) handleAutoStart() {
+//handleAutoStart starts the AutoStart programs, except the ones in skip
+func (h *Handler) handleAutoStart(skip map[string]bool) {
 	isAuthCpy := getIsUserAuth()
 	/* autostart processes even if user is not auth in case of SIGHUP */
 	setIsUserAuth(true)
 	for k, v := range g_procs {
 		var useless []common.ProcStatus
-		if v.AutoStart {
+		//a task which succeeded has nothing left to do
+		if v.AutoStart && !v.LazyStart && !skip[k] && v.GetProcStatus().State != common.Succeeded {
 			h.StartProc(k, &useless)
 		}
 	}
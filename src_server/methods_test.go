@@ -0,0 +1,27 @@
+package main
+
+import (
+	"taskmaster/common"
+	"taskmaster/log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartWaitingProc(t *testing.T) {
+	logw.InitSilent()
+	proc := common.NewProc()
+	proc.Name = "waiting"
+	proc.Command = "/bin/sleep 100"
+	proc.Conditions.Paths = []string{"/no/such/path/here"}
+	g_procs = map[string]*common.Process{"waiting": &proc}
+	defer func() { g_procs = map[string]*common.Process{} }()
+	h := &Handler{}
+	var res []common.ProcStatus
+	assert.Nil(t, h.startProc("waiting", &res))
+	assert.Equal(t, common.Waiting, proc.GetProcStatus().State)
+	assert.NotNil(t, h.startProc("waiting", &res))
+	assert.Equal(t, common.Waiting, proc.GetProcStatus().State)
+	assert.Nil(t, h.stopProc("waiting", &res))
+	assert.Equal(t, common.Stopped, proc.GetProcStatus().State)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"taskmaster/common"
+	"taskmaster/log"
+	"time"
+)
+
+const sampleInterval = 2 * time.Second
+
+type cpuSample struct {
+	pid   int
+	ticks uint64
+	at    time.Time
+}
+
+func allProcs() []*common.Process {
+	var procs []*common.Process
+	lock.RLock()
+	defer lock.RUnlock()
+	for _, proc := range g_procs {
+		procs = append(procs, proc)
+	}
+	return procs
+}
+
+//monitor periodically samples the resource usage of every running process
+func (h *Handler) monitor() {
+	samples := make(map[*common.Process]cpuSample)
+	overSince := make(map[*common.Process]time.Time)
+	overActed := make(map[*common.Process]int)
+	expired := make(map[*common.Notifier]bool)
+	timedOut := make(map[*common.Process]int)
+	for {
+		time.Sleep(sampleInterval)
+		seen := make(map[*common.Process]bool)
+		trees := make(map[string][]int)
+		for _, proc := range allProcs() {
+			seen[proc] = true
+			pid := proc.GetPid()
+			if pid == 0 {
+				proc.SetUsage(0, common.Usage{})
+				delete(samples, proc)
+				continue
+			}
+			usage, tree, err := common.SampleTree(pid)
+			if err != nil {
+				continue
+			}
+			trees[proc.GetName()] = tree
+			now := time.Now()
+			cpu := 0.0
+			if last, ok := samples[proc]; ok && last.pid == pid && usage.CPUTicks >= last.ticks {
+				elapsed := now.Sub(last.at).Seconds()
+				used := float64(usage.CPUTicks-last.ticks) / common.ClockTicks
+				cpu = used / elapsed * 100
+			}
+			samples[proc] = cpuSample{pid: pid, ticks: usage.CPUTicks, at: now}
+			proc.SetUsage(cpu, usage)
+			h.checkMemory(proc, pid, usage.RSS, overSince, overActed)
+			h.checkWatchdog(proc, expired)
+			h.checkRuntime(proc, timedOut)
+		}
+		for proc := range samples {
+			if !seen[proc] {
+				delete(samples, proc)
+				delete(overSince, proc)
+				delete(overActed, proc)
+				delete(timedOut, proc)
+			}
+		}
+		for n := range expired {
+			select {
+			case <-n.Done:
+				delete(expired, n)
+			default:
+			}
+		}
+		if h.reaper {
+			stats := common.AllProcStats()
+			updateLineage(trees, stats)
+			updateStrays(stats)
+		}
+	}
+}
+
+//checkMemory applies the MaxRSS policy of a process once it has stayed over
+//its memory budget for longer than its grace period. acted remembers the pid
+//of the runs already being stopped or restarted
+func (h *Handler) checkMemory(proc *common.Process, pid int, rss uint64, overSince map[*common.Process]time.Time, acted map[*common.Process]int) {
+	if acted[proc] == pid {
+		return
+	}
+	max := proc.GetMaxRSS()
+	if max == 0 || rss <= max {
+		delete(overSince, proc)
+		return
+	}
+	name := proc.GetName()
+	now := time.Now()
+	since, over := overSince[proc]
+	if !over {
+		since = now
+		overSince[proc] = now
+		logw.Warning("Process %s uses %dkB, over its %dkB memory budget", name, rss, max)
+	}
+	if now.Sub(since) < time.Duration(proc.GetMaxRSSGrace())*time.Second {
+		return
+	}
+	delete(overSince, proc)
+	switch proc.GetMaxRSSAction() {
+	case common.ActionAlert:
+		logw.Alert("Process %s has used more than %dkB for %ds (%dkB)", name, max, proc.GetMaxRSSGrace(), rss)
+	case common.ActionStop:
+		logw.Alert("Process %s has used more than %dkB for %ds (%dkB), stopping it", name, max, proc.GetMaxRSSGrace(), rss)
+		acted[proc] = pid
+		proc.SetStopReason(common.ReasonWatchdog)
+		//like a stop by the operator, the process stays stopped
+		go h.queueStop(h.stopManually, name)
+	case common.ActionRestart:
+		logw.Alert("Process %s has used more than %dkB for %ds (%dkB), restarting it", name, max, proc.GetMaxRSSGrace(), rss)
+		acted[proc] = pid
+		proc.SetStopReason(common.ReasonWatchdog)
+		go h.queueStop(h.restartProc, name)
+	}
+}
+
+//checkWatchdog restarts a running process using the notify protocol when it
+//has not sent a WATCHDOG=1 ping for WatchdogSec seconds. The notifiers which
+//already expired are remembered so that a process is restarted only once
+func (h *Handler) checkWatchdog(proc *common.Process, expired map[*common.Notifier]bool) {
+	sec := proc.GetWatchdogSec()
+	n := proc.GetNotifier()
+	if sec == 0 || n == nil || expired[n] || proc.GetProcStatus().State != common.Running {
+		return
+	}
+	if time.Since(n.LastPing()) < time.Duration(sec)*time.Second {
+		return
+	}
+	expired[n] = true
+	name := proc.GetName()
+	logw.Alert("Process %s missed its %ds watchdog, restarting it", name, sec)
+	proc.SetStopReason(common.ReasonNotifyWatchdog)
+	go h.queueStop(h.restartProc, name)
+}
+
+//checkRuntime stops a process running for longer than its MaxRuntime. The
+//run is then handled like an unexpected exit. timedOut remembers the pid
+//of the runs already being stopped
+func (h *Handler) checkRuntime(proc *common.Process, timedOut map[*common.Process]int) {
+	max := proc.GetMaxRuntime()
+	status := proc.GetProcStatus()
+	if max == 0 || timedOut[proc] == status.Pid ||
+		(status.State != common.Running && status.State != common.Starting) {
+		return
+	}
+	if time.Since(status.Runtime) < time.Duration(max)*time.Second {
+		return
+	}
+	timedOut[proc] = status.Pid
+	name := proc.GetName()
+	logw.Alert("Process %s has run for more than %ds, stopping it", name, max)
+	proc.SetStopReason(common.ReasonTimeout)
+	go h.queueStop(h.stopProc, name)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"taskmaster/common"
+	"taskmaster/log"
+)
+
+const prSetChildSubreaper = 36
+
+//lineage remembers which program every known descendant belongs to, so that
+//orphans re-parented to the server can be attributed to their program
+var (
+	lineage     = make(map[int]string)
+	lineageLock = new(sync.Mutex)
+)
+
+func setSubreaper() error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+//updateLineage records the process trees of the programs, and forgets the
+//pids which do not exist anymore
+func updateLineage(trees map[string][]int, stats []common.ProcStat) {
+	alive := make(map[int]bool, len(stats))
+	for _, st := range stats {
+		alive[st.Pid] = true
+	}
+	lineageLock.Lock()
+	defer lineageLock.Unlock()
+	for pid := range lineage {
+		if !alive[pid] {
+			delete(lineage, pid)
+		}
+	}
+	for name, tree := range trees {
+		for _, pid := range tree {
+			lineage[pid] = name
+		}
+	}
+}
+
+//lineageOwner returns the program a descendant belongs to, falling back on
+//its environment for processes which were orphaned before being sampled
+func lineageOwner(pid int) string {
+	lineageLock.Lock()
+	owner, known := lineage[pid]
+	lineageLock.Unlock()
+	if !known {
+		owner = common.ProcessNameOf(pid)
+	}
+	return owner
+}
+
+//isOrphan tells if a process was adopted by the server rather than spawned
+//by it
+func isOrphan(st common.ProcStat) bool {
+	return st.Ppid == os.Getpid() && !common.IsSpawned(st.Pid)
+}
+
+//reapOrphans waits for every adopted zombie. Spawns are locked meanwhile so
+//that a child exiting right after its start is never taken for an orphan
+func reapOrphans() {
+	common.LockSpawns()
+	defer common.UnlockSpawns()
+	for _, st := range common.AllProcStats() {
+		if st.State != 'Z' || !isOrphan(st) {
+			continue
+		}
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(st.Pid, &ws, syscall.WNOHANG, nil)
+		if err != nil || pid != st.Pid {
+			continue
+		}
+		if owner := lineageOwner(pid); owner != "" {
+			logw.Info("Reaped orphan %d of process %s, exit status %d", pid, owner, ws.ExitStatus())
+		} else {
+			logw.Info("Reaped orphan %d, exit status %d", pid, ws.ExitStatus())
+		}
+	}
+}
+
+//updateStrays reports, for every program, the live descendants that were
+//re-parented to the server
+func updateStrays(stats []common.ProcStat) {
+	strays := make(map[string][]int)
+	for _, st := range stats {
+		if st.State == 'Z' || !isOrphan(st) {
+			continue
+		}
+		owner := lineageOwner(st.Pid)
+		if owner == "" {
+			continue
+		}
+		strays[owner] = append(strays[owner], common.DescendantsIn(stats, st.Pid)...)
+	}
+	for _, proc := range allProcs() {
+		proc.SetStrays(strays[proc.GetName()])
+	}
+}
+
+//startReaper makes the server adopt the orphaned descendants of its programs
+//and reap them on SIGCHLD
+func (h *Handler) startReaper() {
+	if os.Getpid() != 1 {
+		if err := setSubreaper(); err != nil {
+			logw.Error("Unable to become a child subreaper: %s", err)
+			return
+		}
+	}
+	h.reaper = true
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGCHLD)
+	go func() {
+		for range sig {
+			reapOrphans()
+		}
+	}()
+	reapOrphans()
+}
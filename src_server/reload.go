@@ -1,37 +1,64 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"taskmaster/common"
 	"taskmaster/log"
+	"time"
 )
 
-func (h *Handler) updateWhatMustBeUpdated(newConf map[string]*common.Process) {
+//updateWhatMustBeUpdated applies the new config to the programs and returns
+//the names of the ones it started again
+func (h *Handler) updateWhatMustBeUpdated(newConf map[string]*common.Process) map[string]bool {
 	var toRestart []string
 	var useless []common.ProcStatus
 	for k := range newConf {
 		if proc, exists := getProc(k); exists {
 			procState := proc.GetProcStatus()
 			if procState.State == common.Running || procState.State == common.Starting {
-				if mustBeRestarted(g_procs[k], newConf[k]) {
+				if canReloadInPlace(g_procs[k], newConf[k]) {
+					proc.ApplyReloadable(newConf[k])
+					updateProc(g_procs[k], newConf[k])
+					h.reloadProc(k, &useless)
+				} else if mustBeRestarted(g_procs[k], newConf[k]) {
 					toRestart = append(toRestart, k)
 					h.StopProc(k, &useless)
 					replaceProcess(k, newConf)
 				} else {
+					schedChanged := !proc.GetSched().Equal(newConf[k].GetSched())
 					updateProc(g_procs[k], newConf[k])
+					if schedChanged {
+						if err := proc.ApplySched(); err != nil {
+							logw.Warning("Unable to update scheduling of %s: %s", k, err)
+						}
+					}
 				}
-			} else if procState.State == common.Backoff {
+			} else if procState.State == common.Backoff || procState.State == common.Waiting {
 				toRestart = append(toRestart, k)
 				h.StopProc(k, &useless)
 				replaceProcess(k, newConf)
+			} else if procState.State == common.Quarantined {
+				//a changed config gives a quarantined process another chance
+				if mustBeRestarted(g_procs[k], newConf[k]) ||
+					newConf[k].RestartLimit != proc.GetRestartLimit() ||
+					newConf[k].RestartWindow != proc.GetRestartWindow() {
+					logw.Info("Config of %s changed, releasing it from quarantine", k)
+				} else {
+					newConf[k].State = procState.State
+				}
+				replaceProcess(k, newConf)
 			} else {
 				newConf[k].State = procState.State
 				replaceProcess(k, newConf)
@@ -40,13 +67,75 @@ func (h *Handler) updateWhatMustBeUpdated(newConf map[string]*common.Process) {
 			replaceProcess(k, newConf)
 		}
 	}
+	restarted := make(map[string]bool)
 	for _, name := range toRestart {
 		h.StartProc(name, &useless)
+		restarted[name] = true
+	}
+	return restarted
+}
+
+//configDiff summarizes the changes between the running and a new config
+func configDiff(newConf map[string]*common.Process) string {
+	var added, removed, restarted []string
+	lock.RLock()
+	for k, proc := range g_procs {
+		if newProc, exists := newConf[k]; !exists {
+			removed = append(removed, k)
+		} else if mustBeRestarted(proc, newProc) {
+			restarted = append(restarted, k)
+		}
+	}
+	for k := range newConf {
+		if _, exists := g_procs[k]; !exists {
+			added = append(added, k)
+		}
+	}
+	lock.RUnlock()
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(restarted)
+	return fmt.Sprintf("added %v, removed %v, changed %v", added, removed, restarted)
+}
+
+//watchConfig reloads the config every time the config file changes, or the
+//environment file of a Procfile. The reload is requested by the server
+//itself, so it does not depend on a client being authenticated, like the
+//start of the AutoStart programs
+func (h *Handler) watchConfig() error {
+	path, err := filepath.Abs(h.configFile)
+	if err != nil {
+		return err
 	}
+	paths := []string{path}
+	if env := h.procfile.EnvPath(path); common.IsProcfile(path) && env != "" {
+		paths = append(paths, env)
+	}
+	w, err := common.NewWatcher(paths, nil)
+	if err != nil {
+		return err
+	}
+	go func() {
+		var debounce <-chan time.Time
+		for {
+			select {
+			case _, open := <-w.Events:
+				if !open {
+					return
+				}
+				debounce = time.After(time.Duration(common.DflDebounce) * time.Millisecond)
+			case <-debounce:
+				debounce = nil
+				logw.Info("Config file %s changed, reloading", h.configFile)
+				h.reloadConfig("", &[]common.ProcStatus{})
+			}
+		}
+	}()
+	return nil
 }
 
 func listenSIGHUP(filename string, h *Handler) {
-	sig := make(chan os.Signal)
+	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGHUP)
 	go func() {
 		for {
@@ -56,10 +145,15 @@ func listenSIGHUP(filename string, h *Handler) {
 	}()
 }
 
-func loadFileSlice(filename string) ([]*common.Process, error) {
-	configFile, err := ioutil.ReadFile(filename)
+//loadFileSlice reads the programs of the config file, along with its password
+func loadFileSlice(filename string, procfile common.ProcfileOptions) ([]*common.Process, string, error) {
+	configFile, positions, warnings, err := common.ReadConfig(filename, procfile)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	for _, warning := range warnings {
+		logw.Warning(warning)
+		fmt.Fprintln(os.Stderr, warning)
 	}
 	wrapper := struct {
 		Password string
@@ -69,15 +163,9 @@ func loadFileSlice(filename string) ([]*common.Process, error) {
 	var resPtr []*common.Process
 	err = json.Unmarshal(configFile, &wrapper)
 	if err != nil {
-		return nil, err
+		return nil, "", common.ConfigError(filename, err, positions)
 	}
 	programs = wrapper.ProgList
-	if wrapper.Password != getPassword() {
-		setPassword(wrapper.Password)
-		if wrapper.Password != "" {
-			setIsUserAuth(false)
-		}
-	}
 	size := len(programs)
 	programs = make([]common.Process, size)
 	for i := 0; i < size; i++ {
@@ -86,9 +174,10 @@ func loadFileSlice(filename string) ([]*common.Process, error) {
 	wrapper.ProgList = programs
 	err = json.Unmarshal(configFile, &wrapper)
 	if err != nil {
-		return nil, err
+		return nil, "", common.ConfigError(filename, err, positions)
 	}
 	programs = wrapper.ProgList
+	common.ExpandRequires(programs)
 	programs = CreateMultiProcess(programs)
 	for i := range programs {
 		programs[i].Name = strings.TrimSpace(programs[i].Name)
@@ -99,12 +188,31 @@ func loadFileSlice(filename string) ([]*common.Process, error) {
 			fmt.Fprintf(os.Stderr, err.Error())
 		}
 	}
-	return resPtr, nil
+	return resPtr, wrapper.Password, nil
+}
+
+//exportConfig writes a config, whatever its format, as a JSON config once it
+//is known to be valid
+func exportConfig(filename, dest string, procfile common.ProcfileOptions) error {
+	if _, err := LoadFile(filename, procfile); err != nil {
+		return err
+	}
+	content, _, _, err := common.ReadConfig(filename, procfile)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, bytes.TrimSpace(content), "", "\t"); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	return ioutil.WriteFile(dest, out.Bytes(), 0644)
 }
 
-//LoadFile reads the config file
-func LoadFile(filename string) (map[string]*common.Process, error) {
-	progs, err := loadFileSlice(filename)
+//LoadFile reads the config file, with the settings of a Procfile given
+//outside of it
+func LoadFile(filename string, procfile common.ProcfileOptions) (map[string]*common.Process, error) {
+	progs, pass, err := loadFileSlice(filename, procfile)
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +220,16 @@ func LoadFile(filename string) (map[string]*common.Process, error) {
 	for _, ptr := range progs {
 		m[ptr.Name] = ptr
 	}
+	if err := common.CheckRequires(m); err != nil {
+		return nil, err
+	}
+	//the password is applied only once the whole file is known to be valid
+	if pass != getPassword() {
+		setPassword(pass)
+		if pass != "" {
+			setIsUserAuth(false)
+		}
+	}
 	return m, nil
 }
 
@@ -123,6 +241,7 @@ func CreateMultiProcess(progs []common.Process) []common.Process {
 				tmp := p
 				nb := strconv.Itoa(int(i))
 				tmp.Name = p.Name + nb
+				tmp.Env = common.InstanceEnv(p.Env, p.PortPerProc, i)
 				tmp.ProcStatus.Name = tmp.Name
 				newSlice = append(newSlice, tmp)
 			}
@@ -165,9 +284,23 @@ func isEnvEqual(old, new []string) bool {
 	return true
 }
 
+func isStringSliceEqual(old, new []string) bool {
+	if len(old) != len(new) {
+		return false
+	}
+	for i := range old {
+		if old[i] != new[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//mustBeRestarted tells if a running program needs a restart to get its new
+//config. Process.ConfigHash covers the same options
 func mustBeRestarted(old, new *common.Process) bool {
 	switch {
-	case old.Command != new.Command:
+	case old.Command != new.Command || old.Type != new.Type || old.Shell != new.Shell:
 		return true
 	case old.Outfile != new.Outfile:
 		return true
@@ -177,23 +310,74 @@ func mustBeRestarted(old, new *common.Process) bool {
 		return true
 	case old.Umask != new.Umask:
 		return true
-	case !isEnvEqual(old.Env, new.Env):
+	case old.User != new.User || old.Group != new.Group:
+		return true
+	case !reflect.DeepEqual(old.Rlimits, new.Rlimits):
+		return true
+	case !isStringSliceEqual(old.Namespaces, new.Namespaces) || old.PrivateTmp != new.PrivateTmp:
+		return true
+	case !isStringSliceEqual(old.ReadOnlyPaths, new.ReadOnlyPaths) || old.Chroot != new.Chroot:
+		return true
+	case !isEnvEqual(old.Env, new.Env) || old.InheritEnv != new.InheritEnv:
+		return true
+	case old.Notify != new.Notify:
+		return true
+	case !isStringSliceEqual(old.Sockets, new.Sockets):
 		return true
 	default:
 		return false
 	}
 }
 
+//canReloadInPlace tells if a running program can get its new config by
+//reloading itself: all the changes needing a restart are in options it
+//marked as Reloadable
+func canReloadInPlace(old, new *common.Process) bool {
+	if len(new.Reloadable) == 0 || !new.CanReload() || !mustBeRestarted(old, new) {
+		return false
+	}
+	old.Lock.RLock()
+	merged := *old
+	old.Lock.RUnlock()
+	merged.Lock = &sync.RWMutex{}
+	merged.ApplyReloadable(new)
+	return !mustBeRestarted(&merged, new)
+}
+
 func updateProc(old, new *common.Process) {
 	old.Lock.Lock()
 	defer old.Lock.Unlock()
 	old.AutoStart = new.AutoStart
+	old.Nice = new.Nice
+	old.IOClass = new.IOClass
+	old.IOPriority = new.IOPriority
+	old.CPUAffinity = new.CPUAffinity
+	old.OOMScoreAdjust = new.OOMScoreAdjust
 	old.AutoRestart = new.AutoRestart
 	old.ExitCodes = new.ExitCodes
 	old.StartTime = new.StartTime
 	old.StartRetries = new.StartRetries
 	old.StopSignal = new.StopSignal
 	old.StopTime = new.StopTime
+	old.StopSequence = new.StopSequence
+	old.ReloadSignal = new.ReloadSignal
+	old.ReloadCommand = new.ReloadCommand
+	old.Reloadable = new.Reloadable
+	old.Hooks = new.Hooks
+	old.WatchdogSec = new.WatchdogSec
+	old.LazyStart = new.LazyStart
+	old.Watch = new.Watch
+	old.MaxRSS = new.MaxRSS
+	old.MaxRSSAction = new.MaxRSSAction
+	old.MaxRSSGrace = new.MaxRSSGrace
+	old.RestartLimit = new.RestartLimit
+	old.MaxRuntime = new.MaxRuntime
+	old.Conditions = new.Conditions
+	old.Requires = new.Requires
+	old.CoreDumps = new.CoreDumps
+	old.CrashDir = new.CrashDir
+	old.CrashLines = new.CrashLines
+	old.RestartWindow = new.RestartWindow
 }
 
 func replaceProcess(k string, newConf map[string]*common.Process) {
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"net/rpc"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,7 +33,15 @@ type Handler struct {
 	Response            chan error
 	methodMap           map[string]MethodFunc
 	configFile, logfile string
+	procfile            common.ProcfileOptions
+	stateFile           string
 	Pause, Continue     chan bool
+	reaper              bool
+	dirty               chan bool
+	binary              string
+	listener            net.Listener
+	lazy                map[string]chan bool
+	watchers            map[string]*common.Watcher
 }
 
 func getProc(k string) (res *common.Process, exists bool) {
@@ -75,6 +85,9 @@ func (h *Handler) AddMethod(action common.ServerMethod, res *[]common.ProcStatus
 		return errors.New("No such method")
 	}
 	action.Result = res
+	if (action.MethodName == "StopProc" || action.MethodName == "RestartProc") && h.isUserAuth() {
+		h.preStop(action.Param)
+	}
 	h.Actions <- action
 	if action.MethodName == "Shutdown" {
 		defer close(h.Actions)
@@ -83,6 +96,14 @@ func (h *Handler) AddMethod(action common.ServerMethod, res *[]common.ProcStatus
 	return ret
 }
 
+//queue runs a method through the action loop on behalf of the server itself,
+//so it does not require the client to be authenticated
+func (h *Handler) queue(method MethodFunc, param string) error {
+	var res []common.ProcStatus
+	h.Actions <- common.ServerMethod{Param: param, Method: method, Result: &res}
+	return <-h.Response
+}
+
 func (h *Handler) init(config, log string) {
 	h.methodMap = map[string]MethodFunc{
 		"StartProc":   h.StartProc,
@@ -90,6 +111,9 @@ func (h *Handler) init(config, log string) {
 		"RestartProc": h.RestartProc,
 		"Reload":      h.ReloadConfig,
 		"Shutdown":    h.Shutdown,
+		"Upgrade":     h.Upgrade,
+		"ReleaseProc": h.ReleaseProc,
+		"ReloadProc":  h.ReloadProc,
 	}
 	h.logfile = log
 	h.configFile = config
@@ -97,43 +121,81 @@ func (h *Handler) init(config, log string) {
 	h.Response = make(chan error)
 	h.Continue = make(chan bool)
 	h.Pause = make(chan bool)
+	h.dirty = make(chan bool, 1)
 }
 
 func main() {
+	//the server binary re-executes itself to set up each program
+	if spec := os.Getenv(common.EnvLaunchSpec); spec != "" {
+		common.Launch(spec)
+	}
 	port := flag.Uint("p", 4242, "Server port")
-	configFile := flag.String("c", "./config.json", "Config-file name")
+	configFile := flag.String("c", "./config.json", "Config-file name, JSON, YAML (.yaml, .yml), supervisord (.ini, .conf) or Procfile")
 	logfile := flag.String("l", "./taskmaster_logs", "Taskmaster's log file")
 	logsize := flag.Uint("s", 65535, "Max size of a log file")
 	lognb := flag.Uint("n", 8, "Max number of log files")
 	genPassword := flag.Bool("h", false, "Generate password hash")
 	httpFlag := flag.Bool("b", true, "Active http server")
+	subreaper := flag.Bool("r", false, "Adopt and reap orphaned descendants of programs")
+	watchConfig := flag.Bool("w", false, "Reload the config automatically when the config file changes")
+	stateFile := flag.String("S", "./taskmaster_state", "State file used to re-adopt programs after a restart, empty to disable")
+	formation := flag.String("m", "", "Number of processes of each type of a Procfile, as in web=2,worker=0, all=n for the others")
+	envFile := flag.String("e", common.DflProcfileEnv, "Environment file of a Procfile, relative to its directory")
+	exportFile := flag.String("x", "", "Write the config, e.g. an imported supervisord one, as a JSON config to this file and exit")
+	upgradeState := flag.String("U", "", "Check that the server can resume an upgrade from this state file and exit, used by upgrades")
 	flag.Parse()
+	procfile := common.ProcfileOptions{Formation: *formation, Env: *envFile}
+
+	if *upgradeState != "" {
+		os.Exit(upgradeReady(*configFile, procfile, *upgradeState))
+	}
 
 	if *genPassword {
 		generateHash()
 		return
 	}
+	if *exportFile != "" {
+		logw.InitSilent()
+		if err := exportConfig(*configFile, *exportFile, procfile); err != nil {
+			log.Fatal("Unable to export config file: ", err)
+		}
+		return
+	}
 	h := new(Handler)
 	h.init(*configFile, *logfile)
+	h.procfile = procfile
+	h.stateFile = *stateFile
 
 	logw.InitSilent()
 	err := logw.InitRotatingLog(h.logfile, int(*logsize), int(*lognb))
 	if err != nil {
 		log.Fatal("Failed to open log file")
 	}
-	g_procs, err = LoadFile(h.configFile)
+	if h.binary, err = exec.LookPath(os.Args[0]); err == nil {
+		h.binary, err = filepath.Abs(h.binary)
+	}
+	if err != nil {
+		log.Fatal("Unable to locate server binary")
+	}
+	//unlike the binary used for upgrades, this one is always our own version
+	common.LauncherPath = "/proc/self/exe"
+	g_procs, err = LoadFile(h.configFile, h.procfile)
 	if err != nil {
-		log.Fatal("Unable to load config file")
+		log.Fatal("Unable to load config file: ", err)
 	}
 	err = rpc.Register(h)
 	if err != nil {
 		log.Fatal(err)
 	}
 	rpc.HandleHTTP()
-	listener, err := net.Listen("tcp", ":"+strconv.FormatUint(uint64(*port), 10))
+	listener, err := inheritedListener()
+	if err == nil && listener == nil {
+		listener, err = net.Listen("tcp", ":"+strconv.FormatUint(uint64(*port), 10))
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	h.listener = listener
 	go func() {
 		for {
 			select {
@@ -150,8 +212,34 @@ func main() {
 			}
 		}
 	}()
-	h.handleAutoStart()
+	upgrading := os.Getenv(envUpgradeState) != ""
+	if h.stateFile != "" {
+		common.StateHook = h.notifyTransition
+		go h.stateWriter()
+	}
+	//the programs kept by an upgrade stay in their state
+	var resumed map[string]bool
+	if upgrading {
+		resumed = h.resumeUpgrade()
+	} else if h.stateFile != "" {
+		h.readoptProcs()
+	}
+	//the children kept by an upgrade are registered by now, so the reaper
+	//does not take them for orphans
+	if *subreaper || os.Getpid() == 1 {
+		h.startReaper()
+	}
+	h.updateSockets()
+	h.updateWatchers()
+	h.handleAutoStart(resumed)
+	go h.monitor()
 	listenSIGHUP(*configFile, h)
+	if *watchConfig {
+		if err := h.watchConfig(); err != nil {
+			logw.Error("Unable to watch config file %s: %s", h.configFile, err)
+		}
+	}
+	listenSIGUSR2(h)
 	if *httpFlag {
 		http.HandleFunc("/", generateRenderer(h))
 	}
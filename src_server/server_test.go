@@ -1,10 +1,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
 	"taskmaster/common"
+	"taskmaster/log"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 func TestNewProc(t *testing.T) {
@@ -12,7 +17,7 @@ func TestNewProc(t *testing.T) {
 	if p.Umask != 022 {
 		t.Errorf("Wrong umask")
 	}
-	assert.Equal(t, 3, p.StartRetries)
+	assert.Equal(t, uint(3), p.StartRetries)
 	assert.Equal(t, false, p.AutoStart)
 	assert.Equal(t, common.DflNumProcs, p.NumProcs)
 	assert.Equal(t, common.DflAutoRestart, p.AutoRestart)
@@ -20,37 +25,39 @@ func TestNewProc(t *testing.T) {
 }
 
 func TestLoadFile(t *testing.T) {
-	err := LoadFile("../config/config.json")
+	procs, err := LoadFile("../config/config.json", common.ProcfileOptions{})
 	assert.Nil(t, err)
-	proc, exists := g_procs["TailDeFou0"]
+	proc, exists := procs["TailDeFou0"]
 	assert.Equal(t, exists, true)
 	assert.Equal(t, proc.Command, "/usr/bin/tail -f /tmp/FICHIER")
 	assert.Equal(t, proc.Outfile, "/tmp/tail_log_out")
 	assert.Equal(t, proc.Errfile, "/tmp/tail_log_err")
-	assert.Equal(t, 4, len(g_procs))
-	if g_procs["TailDeFou0"].Umask != 022 {
+	assert.Equal(t, 5, len(procs))
+	if procs["TailDeFou0"].Umask != 022 {
 		t.Errorf("LOL T NULL")
 	}
-	g_procs = map[string]*common.Process{}
 }
 
 func TestEmptyFields(t *testing.T) {
-	err := LoadFile("../config/invalid.json")
+	procs, err := LoadFile("../config/invalid.json", common.ProcfileOptions{})
 	assert.Nil(t, err)
-	_, exists := g_procs["NONAME"]
+	_, exists := procs["NONAME"]
 	assert.False(t, exists)
-	_, exists = g_procs[""]
+	_, exists = procs[""]
 	assert.False(t, exists)
-	_, exists = g_procs["NOCOMMAND"]
+	_, exists = procs["NOCOMMAND"]
 	assert.False(t, exists)
-	_, exists = g_procs["NORMAL"]
+	_, exists = procs["NORMAL"]
 	assert.True(t, exists)
-	assert.Equal(t, 1, len(g_procs))
-	g_procs = map[string]*common.Process{}
+	assert.Equal(t, 1, len(procs))
 }
 
 func TestPassword(t *testing.T) {
-	err := LoadFile("../config/password.json")
+	//the password is typed on the terminal
+	if !terminal.IsTerminal(int(syscall.Stderr)) {
+		t.Skip("no terminal to type the password on")
+	}
+	_, err := LoadFile("../config/password.json", common.ProcfileOptions{})
 	if err != nil {
 		t.Fatal()
 	}
@@ -59,3 +66,26 @@ func TestPassword(t *testing.T) {
 	t.Log("Test mot de passe incorrect (motdepasse)\n")
 	assert.False(t, checkPassword(true))
 }
+
+func TestPasswordKeptOnInvalidConfig(t *testing.T) {
+	logw.InitSilent()
+	setPassword("")
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := `{"Password": "secret", "ProgList": [{"Name": "a", "Command": "/bin/ls", "Requires": ["missing"]}]}`
+	assert.Nil(t, ioutil.WriteFile(path, []byte(config), 0644))
+	_, err := LoadFile(path, common.ProcfileOptions{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "", getPassword())
+}
+
+func TestCreateMultiProcessPorts(t *testing.T) {
+	p := common.NewProc()
+	p.Name = "web"
+	p.NumProcs = 2
+	p.PortPerProc = true
+	p.Env = []string{"A=1", "PORT=5000"}
+	procs := CreateMultiProcess([]common.Process{p})
+	assert.Equal(t, 2, len(procs))
+	assert.Equal(t, []string{"A=1", "PORT=5000"}, procs[0].Env)
+	assert.Equal(t, []string{"A=1", "PORT=5001"}, procs[1].Env)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"taskmaster/common"
+	"taskmaster/log"
+	"time"
+)
+
+const envUpgradeSockets = "TASKMASTER_UPGRADE_SOCKETS"
+
+//watchLazy starts a lazy program on the first connection to one of its
+//sockets, whenever the program is not running and was not stopped by the
+//operator
+func (h *Handler) watchLazy(name string, socket *os.File, stop chan bool) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		proc, exists := getProc(name)
+		if !exists {
+			return
+		}
+		state := proc.GetProcStatus().State
+		if (state != common.Stopped && state != common.Exited) || proc.GetManualStop() {
+			time.Sleep(time.Second)
+			continue
+		}
+		ready, err := common.WaitReadable(int(socket.Fd()), time.Second)
+		if err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+		if ready {
+			logw.Info("Incoming connection, starting %s", name)
+			h.queue(h.startProc, name)
+		}
+	}
+}
+
+//updateSockets binds the sockets of the programs, closes the ones which are
+//not used anymore, and watches the sockets of the lazy programs
+func (h *Handler) updateSockets() {
+	used := make(map[string]bool)
+	for _, stop := range h.lazy {
+		close(stop)
+	}
+	h.lazy = make(map[string]chan bool)
+	for _, proc := range allProcs() {
+		name := proc.GetName()
+		for _, address := range proc.GetSockets() {
+			used[address] = true
+			socket, err := common.ListenSocket(address)
+			if err != nil {
+				logw.Error("Unable to listen on %s for %s: %s", address, name, err)
+				continue
+			}
+			if proc.GetLazyStart() {
+				if h.lazy[name] == nil {
+					h.lazy[name] = make(chan bool)
+				}
+				go h.watchLazy(name, socket, h.lazy[name])
+			}
+		}
+	}
+	common.CloseUnusedSockets(used)
+}
+
+//upgradeSockets returns the bound sockets to keep across an upgrade, and
+//describes them as fd:address pairs
+func upgradeSockets() ([]*os.File, string) {
+	var files []*os.File
+	var spec []string
+	for address, file := range common.Sockets() {
+		spec = append(spec, strconv.Itoa(int(file.Fd()))+":"+address)
+		files = append(files, file)
+	}
+	return files, strings.Join(spec, ";")
+}
+
+//inheritSockets registers the sockets passed by the previous server
+func inheritSockets() {
+	spec := os.Getenv(envUpgradeSockets)
+	os.Unsetenv(envUpgradeSockets)
+	if spec == "" {
+		return
+	}
+	for _, entry := range strings.Split(spec, ";") {
+		spl := strings.SplitN(entry, ":", 2)
+		fd, err := strconv.Atoi(spl[0])
+		if err != nil || len(spl) != 2 {
+			continue
+		}
+		common.InheritSocket(spl[1], common.InheritedFile(fd, spl[1]))
+	}
+}
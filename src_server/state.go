@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"taskmaster/common"
+	"taskmaster/log"
+)
+
+//stateWriter saves the runtime state of the processes every time it is
+//notified of a transition. Notifications are coalesced, so a transition never
+//waits for the file to be written
+func (h *Handler) stateWriter() {
+	for range h.dirty {
+		if err := saveState(h.stateFile); err != nil {
+			logw.Error("Unable to save state file %s: %s", h.stateFile, err)
+		}
+	}
+}
+
+func (h *Handler) notifyTransition(p *common.Process) {
+	select {
+	case h.dirty <- true:
+	default:
+	}
+}
+
+func currentState() []common.ProcState {
+	var states []common.ProcState
+	for _, proc := range allProcs() {
+		states = append(states, proc.GetProcState())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+func saveState(filename string) error {
+	tmp, err := os.OpenFile(filename+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = writeState(tmp, currentState())
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+func writeState(file *os.File, states []common.ProcState) error {
+	content, err := json.MarshalIndent(states, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	return err
+}
+
+func loadState(filename string) ([]common.ProcState, error) {
+	var states []common.ProcState
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(content, &states)
+	return states, err
+}
+
+//readoptProcs resumes the supervision of the programs which kept running
+//while the server was down
+func (h *Handler) readoptProcs() {
+	states, err := loadState(h.stateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logw.Warning("Unable to read state file %s: %s", h.stateFile, err)
+		}
+		return
+	}
+	h.readopt(states)
+}
+
+//readopt re-adopts the programs which are still running, and returns the
+//states of the others
+func (h *Handler) readopt(states []common.ProcState) []common.ProcState {
+	var others []common.ProcState
+	for _, state := range states {
+		proc, exists := getProc(state.Name)
+		stdout, stderr := state.InheritedLogs()
+		if !exists || !proc.CanAdopt(state) {
+			if stdout != nil {
+				stdout.Close()
+			}
+			if stderr != nil {
+				stderr.Close()
+			}
+			others = append(others, state)
+			continue
+		}
+		proc.Adopt(state)
+		if stdout != nil {
+			proc.SetStdout(stdout)
+		}
+		if stderr != nil {
+			proc.SetStderr(stderr)
+		}
+		var useless []common.ProcStatus
+		if err := h.startProc(state.Name, &useless); err != nil {
+			logw.Warning("Unable to re-adopt process %s: %s", state.Name, err)
+			continue
+		}
+		logw.Info("Re-adopted process %s with pid %d", state.Name, state.Pid)
+	}
+	return others
+}
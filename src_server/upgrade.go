@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"taskmaster/common"
+	"taskmaster/log"
+	"time"
+)
+
+const (
+	envUpgradeState    = "TASKMASTER_UPGRADE_STATE"
+	envUpgradeListener = "TASKMASTER_UPGRADE_LISTENER"
+	//how long the response to an upgrade request is given to reach the client
+	upgradeDelay = time.Second
+	//how long the new binary is given to tell it can resume the supervision
+	upgradeCheckTimeout = 10 * time.Second
+)
+
+//upgrade checks that the server binary can be run, then re-executes it in
+//place once the client has been answered
+func (h *Handler) upgrade(param string, res *[]common.ProcStatus) error {
+	info, err := os.Stat(h.binary)
+	if err == nil && (!info.Mode().IsRegular() || info.Mode()&0111 == 0) {
+		err = errors.New(fmt.Sprintf("%s is not an executable file", h.binary))
+	}
+	if err != nil {
+		logw.Error("Upgrade failed: %s", err)
+		return err
+	}
+	*res = []common.ProcStatus{{State: "Server is being upgraded"}}
+	go func() {
+		time.Sleep(upgradeDelay)
+		h.queue(h.execUpgrade, "")
+	}()
+	return nil
+}
+
+//execUpgrade replaces the server with a fresh image of its binary. The pid
+//is kept, so the programs remain its children, and so are the control
+//listener, the sockets and the log files of the programs. The new image
+//resumes the supervision from the state of every program. It runs in the
+//action loop, so that no command changes a program meanwhile
+func (h *Handler) execUpgrade(param string, res *[]common.ProcStatus) error {
+	listener, err := h.listener.(*net.TCPListener).File()
+	if err != nil {
+		logw.Error("Upgrade failed: %s", err)
+		return err
+	}
+	defer listener.Close()
+	inherited := []*os.File{listener}
+	var states []common.ProcState
+	for _, proc := range allProcs() {
+		state := proc.GetProcState()
+		if out := proc.GetStdout(); out != nil {
+			state.Stdout = int(out.Fd())
+			inherited = append(inherited, out)
+		}
+		if errf := proc.GetStderr(); errf != nil {
+			state.Stderr = int(errf.Fd())
+			inherited = append(inherited, errf)
+		}
+		states = append(states, state)
+	}
+	sockets, spec := upgradeSockets()
+	inherited = append(inherited, sockets...)
+	stateFile, err := ioutil.TempFile("", "taskmaster_upgrade")
+	if err != nil {
+		logw.Error("Upgrade failed: %s", err)
+		return err
+	}
+	err = writeState(stateFile, states)
+	stateFile.Close()
+	if err == nil {
+		//past the exec there is no way back, so the new binary first proves
+		//it can take over
+		err = h.checkUpgrade(stateFile.Name())
+	}
+	if err != nil {
+		os.Remove(stateFile.Name())
+		logw.Error("Upgrade failed: %s", err)
+		return err
+	}
+	for _, file := range inherited {
+		if err = setInheritable(file); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		killExecs()
+		logw.Info("Upgrading server in place with %s", h.binary)
+		env := append(os.Environ(), envUpgradeState+"="+stateFile.Name(),
+			envUpgradeListener+"="+strconv.Itoa(int(listener.Fd())), envUpgradeSockets+"="+spec)
+		err = syscall.Exec(h.binary, os.Args, env)
+	}
+	//still there, the new image never ran
+	for _, file := range inherited {
+		syscall.CloseOnExec(int(file.Fd()))
+	}
+	os.Remove(stateFile.Name())
+	logw.Error("Upgrade failed: %s", err)
+	return err
+}
+
+//checkUpgrade runs the new binary with the arguments of the server, asking
+//it to load the config and the upgrade state then write a ready byte on its
+//fd 3. A binary which does not know the -U flag fails right away
+func (h *Handler) checkUpgrade(stateFile string) error {
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+	var output bytes.Buffer
+	cmd := exec.Command(h.binary, append(os.Args[1:], "-U", stateFile)...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.ExtraFiles = []*os.File{readyWriter}
+	err = common.StartCmd(cmd)
+	readyWriter.Close()
+	if err != nil {
+		return err
+	}
+	ended := make(chan error, 1)
+	go func() {
+		ended <- common.WaitCmd(cmd)
+	}()
+	ready.SetReadDeadline(time.Now().Add(upgradeCheckTimeout))
+	n, _ := ready.Read(make([]byte, 1))
+	if n == 0 {
+		cmd.Process.Kill()
+	}
+	err = <-ended
+	if n == 1 && err == nil {
+		return nil
+	}
+	reason := strings.TrimSpace(output.String())
+	if err != nil {
+		reason = strings.TrimSpace(reason + " " + err.Error())
+	}
+	if reason == "" {
+		reason = "it did not report it was ready"
+	}
+	return errors.New(fmt.Sprintf("%s cannot take over: %s", h.binary, reason))
+}
+
+//upgradeReady is run by the new binary in place of the server on behalf of
+//checkUpgrade. It returns the exit status of the check
+func upgradeReady(configFile string, procfile common.ProcfileOptions, stateFile string) int {
+	logw.InitSilent()
+	if _, err := LoadFile(configFile, procfile); err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to load config file:", err)
+		return 1
+	}
+	if _, err := loadState(stateFile); err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to read upgrade state:", err)
+		return 1
+	}
+	if _, err := os.NewFile(3, "ready").Write([]byte{1}); err != nil {
+		return 1
+	}
+	return 0
+}
+
+//setInheritable keeps a file open across the exec of an upgrade
+func setInheritable(file *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_FCNTL, file.Fd(), syscall.F_SETFD, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+//inheritedListener returns the control listener kept by the previous image
+//of the server during an upgrade, or nil
+func inheritedListener() (net.Listener, error) {
+	fd, err := strconv.Atoi(os.Getenv(envUpgradeListener))
+	os.Unsetenv(envUpgradeListener)
+	if err != nil {
+		return nil, nil
+	}
+	file := common.InheritedFile(fd, "listener")
+	defer file.Close()
+	return net.FileListener(file)
+}
+
+//resumeUpgrade re-adopts the programs handed over by the previous image of
+//the server and puts the others back in their state. It returns the names
+//of the programs it knew about
+func (h *Handler) resumeUpgrade() map[string]bool {
+	filename := os.Getenv(envUpgradeState)
+	os.Unsetenv(envUpgradeState)
+	inheritSockets()
+	states, err := loadState(filename)
+	os.Remove(filename)
+	if err != nil {
+		logw.Error("Unable to read upgrade state %s: %s", filename, err)
+		return nil
+	}
+	resumed := make(map[string]bool)
+	for _, state := range states {
+		if proc, exists := getProc(state.Name); exists {
+			proc.SetHistory(state.History)
+			proc.SetManualStop(state.ManualStop)
+		}
+		resumed[state.Name] = true
+	}
+	for _, state := range h.readopt(states) {
+		h.restoreState(state)
+	}
+	logw.Info("Resumed supervision after upgrade")
+	return resumed
+}
+
+//restoreState puts a program which was not running back in its state. The
+//programs which were about to be started are started again
+func (h *Handler) restoreState(state common.ProcState) {
+	proc, exists := getProc(state.Name)
+	if !exists {
+		return
+	}
+	switch state.State {
+	case common.Stopped:
+	case common.Exited, common.Fatal, common.Quarantined, common.Succeeded, common.Failed:
+		proc.SetStatus(state.State)
+	default:
+		var useless []common.ProcStatus
+		if err := h.startProc(state.Name, &useless); err != nil {
+			logw.Warning("Unable to restart process %s after upgrade: %s", state.Name, err)
+		}
+	}
+}
+
+func listenSIGUSR2(h *Handler) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR2)
+	go func() {
+		for {
+			<-sig
+			h.queue(h.upgrade, "")
+		}
+	}()
+}
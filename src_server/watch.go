@@ -0,0 +1,66 @@
+package main
+
+import (
+	"syscall"
+	"taskmaster/common"
+	"taskmaster/log"
+	"time"
+)
+
+//updateWatchers replaces the file watchers of the programs with watchers
+//for the current configuration
+func (h *Handler) updateWatchers() {
+	for _, w := range h.watchers {
+		w.Close()
+	}
+	h.watchers = make(map[string]*common.Watcher)
+	for _, proc := range allProcs() {
+		name := proc.GetName()
+		cfg := proc.GetWatch()
+		if len(cfg.Paths) == 0 {
+			continue
+		}
+		w, err := common.NewWatcher(cfg.Paths, cfg.Ignore)
+		if err != nil {
+			logw.Error("Unable to watch files of %s: %s", name, err)
+			continue
+		}
+		h.watchers[name] = w
+		go h.watchFiles(name, w, cfg)
+	}
+}
+
+//watchFiles restarts or signals a program once its watched files stop
+//changing for the debounce interval
+func (h *Handler) watchFiles(name string, w *common.Watcher, cfg common.Watch) {
+	var changed string
+	var debounce <-chan time.Time
+	for {
+		select {
+		case path, open := <-w.Events:
+			if !open {
+				return
+			}
+			changed = path
+			debounce = time.After(time.Duration(cfg.Debounce) * time.Millisecond)
+		case <-debounce:
+			debounce = nil
+			proc, exists := getProc(name)
+			if !exists {
+				continue
+			}
+			state := proc.GetProcStatus().State
+			if state != common.Running && state != common.Starting {
+				logw.Info("File %s changed, %s is not running", changed, name)
+				continue
+			}
+			if cfg.Signal != 0 {
+				logw.Info("File %s changed, sending signal %d (%s) to %s", changed, cfg.Signal, cfg.Signal, name)
+				syscall.Kill(proc.GetPid(), cfg.Signal)
+			} else {
+				logw.Info("File %s changed, restarting %s", changed, name)
+				go h.queueStop(h.restartProc, name)
+			}
+		}
+	}
+}